@@ -1,4 +1,9 @@
 set +eu
+# -tags nomecabでビルドする場合(CGO_ENABLED=0)は、mecabの設定は不要
+if [ "$CGO_ENABLED" = "0" ]; then
+      set -eu
+      return 0
+fi
 if [ -z "$CGO_LDFLAGS" ]; then
       echo "CGO_LDFLAGS is not defined."
       echo "Ex: export CGO_LDFLAGS=\"-L/usr/local/lib/ -lmecab -lstdc++\""
@@ -12,4 +12,4 @@ popd
 
 echo "build..."
 cd `/usr/bin/dirname $0`/../
-GOPATH=$GOPATH go build -o bin/tag-predict go-tag-predict
+GOPATH=$GOPATH go build "$@" -o bin/tag-predict go-tag-predict
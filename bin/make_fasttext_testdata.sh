@@ -0,0 +1,24 @@
+#!/bin/sh
+# fasttextパッケージのテスト用に、本物のfastTextで学習したモデルとpredict-probの出力を作る
+# Ex: bin/make_fasttext_testdata.sh /usr/local/bin/fasttext
+set -eu
+
+FASTTEXT=${1:-fasttext}
+cd `/usr/bin/dirname $0`/../src/go-tag-predict/fasttext/testdata
+
+# 乱数とスレッドを固定して、同じfastTextからは同じモデルが作られるようにする
+ARGS="-dim 8 -epoch 20 -minCount 1 -thread 1 -seed 1"
+for LOSS in softmax hs ova; do
+      $FASTTEXT supervised -input train.txt -output $LOSS -loss $LOSS -bucket 0 $ARGS
+      rm -f $LOSS.vec
+done
+# 単語n-gramのハッシュと量子化済みモデル(.ftz)
+# 量子化には256行以上の行列が必要なので、bucketを指定する
+$FASTTEXT supervised -input train.txt -output ngram -wordNgrams 2 -bucket 1000 $ARGS
+$FASTTEXT quantize -input train.txt -output ngram -qnorm
+rm -f ngram.vec
+
+for MODEL in softmax.bin hs.bin ova.bin ngram.bin ngram.ftz; do
+      $FASTTEXT predict-prob $MODEL input.txt 3 > $MODEL.predict-prob
+done
+$FASTTEXT --version > version.txt 2>&1 || true
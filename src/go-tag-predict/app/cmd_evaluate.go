@@ -0,0 +1,255 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+
+	"go.uber.org/zap"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// RunEvaluate : 評価メイン関数
+//
+// 学習時に分割したテスト用(or 検証用)データを分類して、precision@k, recall@k, F1をタグごとと全体で集計する
+// 結果は標準出力に表形式で、[evaluate] output_fileにJSONで出力する
+func RunEvaluate(ctx context.Context, config *Config, logger *zap.Logger) error {
+	labels, err := loadTagIDFile(config.GetTagIDPath())
+	if err != nil {
+		return err
+	}
+	ft, err := newLabelPredictor(ctx, config, config.GetModelPathForPredict(), config.Evaluate.K)
+	if err != nil {
+		return err
+	}
+	defer ft.Close()
+
+	e, err := evaluateFile(ctx, config, ft, config.GetEvaluateSourcePath(), config.Evaluate.K)
+	if err != nil {
+		return err
+	}
+
+	report := e.report(labels.GetReverse())
+	logger.Info("evaluate",
+		zap.Int("documents", report.Documents),
+		zap.Float64("precision", report.Overall.Precision),
+		zap.Float64("recall", report.Overall.Recall))
+	if err := report.WriteTable(os.Stdout); err != nil {
+		return err
+	}
+	out, err := os.Create(config.GetEvaluateReportPath())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer out.Close()
+	return report.WriteJSON(out)
+}
+
+// evaluateFile : fastTextの学習データ形式のファイルを分類して、正解と予測のラベルを集計する
+func evaluateFile(ctx context.Context, config *Config, ft labelPredictor, filePath string, k int) (*evaluator, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	e := newEvaluator(k)
+	eg, ctx := errgroup.WithContext(ctx)
+	limitter := make(chan struct{}, max(0, config.Predict.ParallelsCount-1)) // 同時実行数の制御
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024*64)
+	for scanner.Scan() {
+		gold, tokens := parseSupervisedLine(scanner.Text())
+		if len(gold) == 0 {
+			continue
+		}
+		limitter <- struct{}{}
+		if ctx.Err() != nil {
+			break
+		}
+		func(gold []string, tokens string) {
+			eg.Go(func() error {
+				defer func() {
+					<-limitter
+				}()
+				predictions, err := ft.Predict(ctx, tokens)
+				if err != nil {
+					return err
+				}
+				predicted := make([]string, 0, len(predictions))
+				for _, p := range predictions {
+					predicted = append(predicted, p.Label)
+				}
+				e.add(gold, predicted)
+				return nil
+			})
+		}(gold, tokens)
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return e, nil
+}
+
+// parseSupervisedLine : fastTextの学習データ1行を、ラベルと本文に分ける
+// Example:
+//   parseSupervisedLine("__label__1 __label__2 , 3 4") // []string{"__label__1", "__label__2"}, "3 4"
+func parseSupervisedLine(line string) ([]string, string) {
+	fields := strings.Fields(line)
+	labels := make([]string, 0, 4)
+	i := 0
+	for ; i < len(fields) && strings.HasPrefix(fields[i], "__label__"); i++ {
+		labels = append(labels, fields[i])
+	}
+	if i < len(fields) && fields[i] == "," {
+		i++
+	}
+	return labels, strings.Join(fields[i:], " ")
+}
+
+// EvaluationScore : タグ(or 全体)ごとの評価結果
+type EvaluationScore struct {
+	Tag string `json:"tag,omitempty"`
+	// Gold : 正解データに含まれる数
+	Gold int `json:"gold"`
+	// Predicted : 上位k個に予測された数
+	Predicted int `json:"predicted"`
+	// Correct : 予測が正解だった数
+	Correct   int     `json:"correct"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+}
+
+func (s *EvaluationScore) calc() {
+	if s.Predicted > 0 {
+		s.Precision = float64(s.Correct) / float64(s.Predicted)
+	}
+	if s.Gold > 0 {
+		s.Recall = float64(s.Correct) / float64(s.Gold)
+	}
+	if s.Precision+s.Recall > 0 {
+		s.F1 = 2 * s.Precision * s.Recall / (s.Precision + s.Recall)
+	}
+}
+
+// EvaluationReport : 評価結果
+// Overallは全タグの合計から計算する (マイクロ平均)
+type EvaluationReport struct {
+	K         int                `json:"k"`
+	Documents int                `json:"documents"`
+	Overall   *EvaluationScore   `json:"overall"`
+	Tags      []*EvaluationScore `json:"tags"`
+}
+
+// WriteTable : 表形式で出力する
+func (r *EvaluationReport) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "tag\tgold\tpredicted\tcorrect\tP@%d\tR@%d\tF1\n", r.K, r.K)
+	for _, s := range append(r.Tags, r.Overall) {
+		tag := s.Tag
+		if s == r.Overall {
+			tag = "(overall)"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.4f\t%.4f\t%.4f\n", tag, s.Gold, s.Predicted, s.Correct, s.Precision, s.Recall, s.F1)
+	}
+	fmt.Fprintf(tw, "documents: %d\n", r.Documents)
+	return errors.WithStack(tw.Flush())
+}
+
+// WriteJSON : JSONで出力する
+func (r *EvaluationReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return errors.WithStack(enc.Encode(r))
+}
+
+// evaluator : 正解と予測のラベルを集計する
+// 複数Goルーチンから同時にaddできる
+type evaluator struct {
+	mutex     sync.Mutex
+	k         int
+	documents int
+	scores    map[string]*EvaluationScore
+}
+
+func newEvaluator(k int) *evaluator {
+	return &evaluator{k: k, scores: make(map[string]*EvaluationScore, 1024)}
+}
+
+func (e *evaluator) score(label string) *EvaluationScore {
+	s, ok := e.scores[label]
+	if !ok {
+		s = &EvaluationScore{}
+		e.scores[label] = s
+	}
+	return s
+}
+
+// add : 1文書分の正解ラベルと、確率の高い順に並んだ予測ラベルを追加する
+func (e *evaluator) add(gold []string, predicted []string) {
+	defer e.mutex.Unlock()
+	e.mutex.Lock()
+	e.documents++
+	goldSet := make(map[string]bool, len(gold))
+	for _, label := range gold {
+		if !goldSet[label] {
+			goldSet[label] = true
+			e.score(label).Gold++
+		}
+	}
+	if len(predicted) > e.k {
+		predicted = predicted[:e.k]
+	}
+	for _, label := range predicted {
+		s := e.score(label)
+		s.Predicted++
+		if goldSet[label] {
+			s.Correct++
+		}
+	}
+}
+
+// report : 集計結果を取得する
+// idMapでラベルのIDをタグに戻す。タグは正解データに含まれる数の多い順に並べる
+func (e *evaluator) report(idMap map[int]string) *EvaluationReport {
+	defer e.mutex.Unlock()
+	e.mutex.Lock()
+	r := &EvaluationReport{K: e.k, Documents: e.documents, Overall: &EvaluationScore{}, Tags: make([]*EvaluationScore, 0, len(e.scores))}
+	for label, s := range e.scores {
+		tag := label
+		if id, err := parseLabelID(label); err == nil {
+			if t, ok := idMap[id]; ok {
+				tag = t
+			}
+		}
+		score := *s
+		score.Tag = tag
+		score.calc()
+		r.Tags = append(r.Tags, &score)
+		r.Overall.Gold += s.Gold
+		r.Overall.Predicted += s.Predicted
+		r.Overall.Correct += s.Correct
+	}
+	r.Overall.calc()
+	sort.Slice(r.Tags, func(i, j int) bool {
+		if r.Tags[i].Gold != r.Tags[j].Gold {
+			return r.Tags[i].Gold > r.Tags[j].Gold
+		}
+		return r.Tags[i].Tag < r.Tags[j].Tag
+	})
+	return r
+}
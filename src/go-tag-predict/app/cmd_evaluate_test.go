@@ -0,0 +1,68 @@
+package app
+
+import (
+	"fmt"
+	"os"
+)
+
+func Example_evaluator() {
+	gold, tokens := parseSupervisedLine("__label__1 __label__2 , 3 4 5")
+	fmt.Println(gold, tokens)
+
+	e := newEvaluator(2)
+	e.add(gold, []string{"__label__1", "__label__3", "__label__2"})
+	e.add([]string{"__label__2"}, []string{"__label__2", "__label__1"})
+	e.add([]string{"__label__3"}, []string{"__label__1"})
+	r := e.report(map[int]string{1: "golang", 2: "web", 3: "programming"})
+	r.WriteTable(os.Stdout)
+	r.WriteJSON(os.Stdout)
+	// Output:
+	// [__label__1 __label__2] 3 4 5
+	// tag          gold  predicted  correct  P@2     R@2     F1
+	// web          2     1          1        1.0000  0.5000  0.6667
+	// golang       1     3          1        0.3333  1.0000  0.5000
+	// programming  1     1          0        0.0000  0.0000  0.0000
+	// (overall)    4     5          2        0.4000  0.5000  0.4444
+	// documents: 3
+	// {
+	//   "k": 2,
+	//   "documents": 3,
+	//   "overall": {
+	//     "gold": 4,
+	//     "predicted": 5,
+	//     "correct": 2,
+	//     "precision": 0.4,
+	//     "recall": 0.5,
+	//     "f1": 0.4444444444444445
+	//   },
+	//   "tags": [
+	//     {
+	//       "tag": "web",
+	//       "gold": 2,
+	//       "predicted": 1,
+	//       "correct": 1,
+	//       "precision": 1,
+	//       "recall": 0.5,
+	//       "f1": 0.6666666666666666
+	//     },
+	//     {
+	//       "tag": "golang",
+	//       "gold": 1,
+	//       "predicted": 3,
+	//       "correct": 1,
+	//       "precision": 0.3333333333333333,
+	//       "recall": 1,
+	//       "f1": 0.5
+	//     },
+	//     {
+	//       "tag": "programming",
+	//       "gold": 1,
+	//       "predicted": 1,
+	//       "correct": 0,
+	//       "precision": 0,
+	//       "recall": 0,
+	//       "f1": 0
+	//     }
+	//   ]
+	// }
+}
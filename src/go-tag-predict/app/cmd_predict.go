@@ -2,16 +2,11 @@ package app
 
 import (
 	"context"
-	"go-tag-predict/lambda"
-	"go-tag-predict/osutil"
-	"os"
-	"strconv"
-	"strings"
+	"sync"
 
 	"golang.org/x/sync/errgroup"
 
 	"github.com/mmcdole/gofeed"
-	"github.com/pkg/errors"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -19,21 +14,30 @@ import (
 
 // RunPredict : 分類メイン関数
 func RunPredict(ctx context.Context, config *Config, logger *zap.Logger) error {
-	f, err := os.Open(config.GetTagIDPath())
+	p, err := NewPredictor(ctx, config)
 	if err != nil {
-		return errors.WithStack(err)
+		return err
+	}
+	defer p.Close()
+
+	wb, err := newPinboardWriteback(config, logger)
+	if err != nil {
+		return err
 	}
-	t, err := LoadTagID(f)
+
+	sink, err := OpenPredictSink(ctx, config.Predict)
 	if err != nil {
 		return err
 	}
-	idMap := t.GetReverse()
 
+	stats := &oovStats{}
 	eg, ctx := errgroup.WithContext(ctx)
 	limitter := make(chan struct{}, max(0, config.Predict.ParallelsCount-1)) // 同時実行数の制御
 	for _, rawurl := range config.Predict.FeedURLs {
 		feed, err := LoadFeed(ctx, rawurl, config.CacheDirPath)
 		if err != nil {
+			eg.Wait()
+			sink.Close()
 			return err
 		}
 		for _, item := range feed.Items {
@@ -41,99 +45,109 @@ func RunPredict(ctx context.Context, config *Config, logger *zap.Logger) error {
 			if ctx.Err() != nil {
 				break
 			}
-			func(item *gofeed.Item) {
+			func(rawurl string, item *gofeed.Item) {
 				eg.Go(func() error {
 					defer func() {
 						<-limitter
 					}()
-					return procPage(ctx, config, logger, t, idMap, item)
+					return procPage(ctx, logger, p, sink, wb, stats, rawurl, item)
 				})
-			}(item)
+			}(rawurl, item)
 		}
 	}
 	err = eg.Wait()
+	logger.Info("oov",
+		zap.Int("pages", stats.pages),
+		zap.Float64("avg_oov_rate", stats.average()))
+	if closeErr := sink.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
-func procPage(ctx context.Context, config *Config, logger *zap.Logger, t TagID, idMap map[int]string, item *gofeed.Item) error {
-	content, err := LoadWebContent(ctx, item.Link, config.CacheDirPath)
+
+// oovStats : ページごとの未知語の割合を集計する
+type oovStats struct {
+	mutex sync.Mutex
+	pages int
+	total float64
+}
+
+func (s *oovStats) add(rate float64) {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	s.pages++
+	s.total += rate
+}
+
+func (s *oovStats) average() float64 {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	if s.pages == 0 {
+		return 0
+	}
+	return s.total / float64(s.pages)
+}
+
+func procPage(ctx context.Context, logger *zap.Logger, p *Predictor, sink PredictSink, wb *pinboardWriteback, stats *oovStats, feedURL string, item *gofeed.Item) error {
+	content, err := LoadWebContent(ctx, item.Link, p.config.CacheDirPath)
 	if err != nil {
 		return nil // ページの取得に失敗しても全体の処理を継続する
 	}
-	tokens, err := Tokenize(ctx, config, content)
-	tokens = lambda.MapIntString(t.GetIDs(tokens), strconv.Itoa)
+	out, err := p.PredictText(ctx, content)
 	if err != nil {
 		return err
 	}
-
-	ar, err := predict(ctx, config, idMap, strings.Join(tokens, " "))
-	if err != nil {
+	stats.add(out.OOVRate)
+	logger.Debug("page",
+		zap.String("url", item.Link),
+		zap.Float64("oov_rate", out.OOVRate),
+		zap.String("lang", out.Lang),
+		zap.Array("tag", out.Tags))
+	// タグがなくてもoov_rateを集計できるように出力する
+	record := &PredictRecord{
+		URL:       item.Link,
+		FeedURL:   feedURL,
+		Title:     item.Title,
+		Published: item.PublishedParsed,
+		Tags:      out.Tags,
+		OOVRate:   out.OOVRate,
+		Lang:      out.Lang,
+	}
+	if err := sink.Write(record); err != nil {
 		return err
 	}
-	if len(ar) == 0 {
-		return nil
+	if wb != nil && len(record.Tags) > 0 {
+		return wb.Write(ctx, record)
 	}
-	logger.Info("page",
-		zap.String("url", item.Link),
-		zap.Array("tag", ar))
 	return nil
 }
-func predict(ctx context.Context, config *Config, idMap map[int]string, tokens string) (predictResults, error) {
-	modelPath := config.GetModelPathForPredict()
-	lines, err := osutil.ExecuteCommand(
-		ctx,
-		config.Fasttext.Command,
-		lambda.MapString(config.Fasttext.PredictArgs, func(s string) string {
-			if s == "{MODEL_PATH}" {
-				return modelPath
-			}
-			return s
-		}), tokens)
-	if err != nil {
-		return nil, err
-	}
-	res := make(predictResults, 0, len(lines))
-	for _, line := range lines {
-		ar := strings.SplitN(line, " ", 2)
-		id, err := strconv.Atoi(ar[0][len("__label__"):])
-		if err != nil {
-			return nil, errors.WithStack(err)
-		}
-		probability, err := strconv.ParseFloat(ar[1], 32)
-		if probability < config.Predict.MinProbability {
-			continue
-		}
-		if err != nil {
-			return nil, errors.WithStack(err)
-		}
-		if tag, ok := idMap[id]; ok {
-			res = append(res, &predictResult{tag: tag, probability: probability})
-		}
-	}
-	return res, nil
-}
 
-type predictResult struct {
-	tag         string
-	probability float64
+// PredictResult : 分類結果のタグと確率
+type PredictResult struct {
+	Tag         string  `json:"tag"`
+	Probability float64 `json:"probability"`
 }
 
-type predictResults []*predictResult
+// PredictResults : 確率の高い順に並んだ分類結果
+type PredictResults []*PredictResult
 
-func (ps predictResults) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+// MarshalLogArray : zapのログ出力用
+func (ps PredictResults) MarshalLogArray(enc zapcore.ArrayEncoder) error {
 	for _, p := range ps {
 		enc.AppendObject(p)
 	}
 	return nil
 }
 
-func (p *predictResult) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+// MarshalLogObject : zapのログ出力用
+func (p *PredictResult) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 	if p != nil {
-		enc.AddString("tag", p.tag)
-		enc.AddFloat64("probability", p.probability)
+		enc.AddString("tag", p.Tag)
+		enc.AddFloat64("probability", p.Probability)
 	}
 	return nil
 }
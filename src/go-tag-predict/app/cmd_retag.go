@@ -0,0 +1,318 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"go-tag-predict/lambda"
+	"go-tag-predict/webservice/pinboard"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"go.uber.org/zap"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// RunRetag : タグの付け直しメイン関数
+//
+// 学習と同じブックマークを読み込み、タグがmin_tags個未満のブックマークに予測したタグを追加する
+// タグのあるブックマークは学習データに含まれるので、folds分割の交差検証で、自分を含まないモデルで予測する (学習時のタグをそのまま返さないように)
+// タグのないブックマークは、タグのある全てのブックマークで学習したモデルで予測する
+// 追加するタグの一覧は標準出力に表示し、applyが"file"の場合はXMLファイルに書き出し、"api"の場合はPinboard APIで追加する
+func RunRetag(ctx context.Context, config *Config, logger *zap.Logger) error {
+	docs, err := loadRetagDocs(ctx, config, logger)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(config.GetRetagDirPath(), 0700); err != nil {
+		return errors.WithStack(err)
+	}
+	labels := NewTagID()
+	for _, d := range docs {
+		if d.tokens != "" {
+			labels.GetIDs(d.tags)
+		}
+	}
+	// fold == -1 はタグのないブックマーク
+	for fold := -1; fold < config.Retag.Folds; fold++ {
+		targets := retagTargets(docs, config.Retag.MinTags, fold)
+		if len(targets) == 0 {
+			continue
+		}
+		if err := retagFold(ctx, config, logger, labels, docs, fold, targets); err != nil {
+			return err
+		}
+	}
+
+	if err := writeRetagDiff(os.Stdout, docs); err != nil {
+		return err
+	}
+	if config.Retag.Apply == RetagApplyAPI {
+		return applyRetagAPI(ctx, config, logger, docs)
+	}
+	posts := make([]*pinboard.Post, 0, len(docs))
+	for _, d := range docs {
+		post := *d.post
+		post.Tags = append(nonEmptyTags(post.Tags), d.added...)
+		posts = append(posts, &post)
+	}
+	logger.Info("retag", zap.String("output", config.GetRetagOutputPath()))
+	return pinboard.WriteFile(config.GetRetagOutputPath(), posts)
+}
+
+// retagDoc : タグを付け直すブックマーク
+type retagDoc struct {
+	post *pinboard.Post
+	// tags : [tags]の設定で正規化した既存のタグ
+	tags []string
+	// tokens : 本文の単語IDを空白区切りにしたもの (ページを取得できなかった場合は空文字)
+	tokens string
+	// fold : 交差検証の分割先 (タグのないブックマークは-1, 学習にも予測にも使わないブックマークは-2)
+	fold int
+	// added : 予測して追加するタグ
+	added []string
+}
+
+// loadRetagDocs : 学習ソースのブックマークを全て読み込み、ページを取得して分かち書きする
+// include_private, time_from, time_toの条件に合わないブックマークは、学習にも予測にも使わずにそのまま書き出す
+// タグは[tags]の設定で正規化する。正規化して残るタグがないブックマークは、タグのないブックマークとして予測する
+func loadRetagDocs(ctx context.Context, config *Config, logger *zap.Logger) ([]*retagDoc, error) {
+	filter, err := newPostFilter(config)
+	if err != nil {
+		return nil, err
+	}
+	tn, err := NewTagNormalizer(config.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if err := syncLearningSource(ctx, config, logger); err != nil {
+		return nil, err
+	}
+	itr, err := openLearningSource(config)
+	if err != nil {
+		return nil, err
+	}
+	defer itr.Close()
+	vocab := NewTagID()
+	docs := make([]*retagDoc, 0, 1024)
+	eg, ctx := errgroup.WithContext(ctx)
+	limitter := make(chan struct{}, max(0, config.Supervised.ParallelsCount-1)) // 同時実行数の制御
+	for {
+		post, err := itr.Next()
+		if err != nil {
+			return nil, err
+		}
+		if post == nil {
+			break
+		}
+		d := &retagDoc{post: post, fold: -2}
+		docs = append(docs, d)
+		if !filter.accept(post) {
+			continue
+		}
+		d.fold = -1
+		d.tags = tn.Normalize(post.Tags)
+		limitter <- struct{}{}
+		if ctx.Err() != nil {
+			break
+		}
+		func(d *retagDoc) {
+			eg.Go(func() error {
+				defer func() {
+					<-limitter
+				}()
+				tokens, err := loadPostTokens(ctx, config, logger, d.post)
+				if err != nil {
+					return err
+				}
+				d.tokens = strings.Join(lambda.MapIntString(vocab.GetIDs(tokens), strconv.Itoa), " ")
+				return nil
+			})
+		}(d)
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	if config.Tags.needsCount() {
+		counts := make(map[string]int, 1024)
+		for _, d := range docs {
+			countTags(counts, d.tags)
+		}
+		tn.Limit(counts, config.Tags.MinTagCount, config.Tags.MaxTags)
+	}
+	for _, d := range docs {
+		d.tags = tn.filter(d.tags)
+		if d.fold == -1 && len(d.tags) > 0 {
+			d.fold = foldOf(d.post.Href, config.Supervised.SplitSeed, config.Retag.Folds)
+		}
+	}
+	return docs, nil
+}
+
+func nonEmptyTags(tags []string) []string {
+	res := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag != "" {
+			res = append(res, tag)
+		}
+	}
+	return res
+}
+
+// foldOf : URLとseedのハッシュ値で、交差検証の分割先を決める
+func foldOf(href string, seed int64, folds int) int {
+	sum := sha1.Sum([]byte(strconv.FormatInt(seed, 10) + "\tfold\t" + href))
+	return int(binary.BigEndian.Uint64(sum[:8]) % uint64(folds))
+}
+
+// retagTargets : 分割先foldの、タグがminTags個未満のブックマーク
+func retagTargets(docs []*retagDoc, minTags int, fold int) []*retagDoc {
+	targets := make([]*retagDoc, 0, len(docs))
+	for _, d := range docs {
+		if d.fold == fold && d.tokens != "" && len(d.tags) < minTags {
+			targets = append(targets, d)
+		}
+	}
+	return targets
+}
+
+// retagFold : 分割先fold以外のタグのあるブックマークで学習して、targetsのタグを予測する
+func retagFold(ctx context.Context, config *Config, logger *zap.Logger, labels TagID, docs []*retagDoc, fold int, targets []*retagDoc) error {
+	name := "all"
+	if fold >= 0 {
+		name = "fold" + strconv.Itoa(fold)
+	}
+	modelPath := path.Join(config.GetRetagDirPath(), name)
+	inputPath := modelPath + ".txt"
+	if err := writeRetagInput(inputPath, config.Supervised.LabelMode, labels, docs, fold); err != nil {
+		return err
+	}
+	if err := trainModel(ctx, config, config.Fasttext.SupervisedArgs, modelPath, inputPath); err != nil {
+		return err
+	}
+	// 分割ごとのモデルは大きいので、予測後に削除する
+	defer os.Remove(modelPath + ".bin")
+	defer os.Remove(modelPath + ".vec")
+
+	ft, err := newLabelPredictor(ctx, config, modelPath+".bin", config.Predict.GetTopK())
+	if err != nil {
+		return err
+	}
+	defer ft.Close()
+	p := &Predictor{config: config, idMap: labels.GetReverse(), fasttext: ft}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	limitter := make(chan struct{}, max(0, config.Predict.ParallelsCount-1)) // 同時実行数の制御
+	for _, d := range targets {
+		limitter <- struct{}{}
+		if ctx.Err() != nil {
+			break
+		}
+		func(d *retagDoc) {
+			eg.Go(func() error {
+				defer func() {
+					<-limitter
+				}()
+				tags, err := p.predict(ctx, d.tokens)
+				if err != nil {
+					return err
+				}
+				d.added = addedTags(d.tags, tags)
+				return nil
+			})
+		}(d)
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+	logger.Info("retag fold",
+		zap.String("model", name),
+		zap.Int("targets", len(targets)))
+	return nil
+}
+
+// writeRetagInput : 分割先fold以外のタグのあるブックマークを、fastTextの学習データ形式で書き出す
+func writeRetagInput(filePath string, mode string, labels TagID, docs []*retagDoc, fold int) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	for _, d := range docs {
+		if d.fold != fold && d.fold >= 0 && d.tokens != "" {
+			w.WriteString(supervisedLines(labels.GetIDs(d.tags), d.tokens, mode))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(f.Close())
+}
+
+// addedTags : 予測したタグのうち、既存のタグにないもの
+func addedTags(tags []string, predicted PredictResults) []string {
+	res := make([]string, 0, len(predicted))
+	for _, p := range predicted {
+		if !containsString(tags, p.Tag) {
+			res = append(res, p.Tag)
+		}
+	}
+	return res
+}
+
+// writeRetagDiff : タグを追加するブックマークを「URL<TAB>既存のタグ<TAB>+追加するタグ」の形式で出力する
+func writeRetagDiff(w io.Writer, docs []*retagDoc) error {
+	for _, d := range docs {
+		if len(d.added) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t+%s\n", d.post.Href, strings.Join(d.tags, " "), strings.Join(d.added, " +")); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// applyRetagAPI : Pinboard APIで予測したタグを追加する
+// dry_runの場合は、標準出力に表示した一覧だけでAPIを呼ばない
+func applyRetagAPI(ctx context.Context, config *Config, logger *zap.Logger, docs []*retagDoc) error {
+	if config.Retag.DryRun {
+		logger.Info("retag dry-run")
+		return nil
+	}
+	token := config.Pinboard.GetToken()
+	if token == "" {
+		return errors.New("pinboard token is required. set [pinboard] token or PINBOARD_TOKEN")
+	}
+	c := pinboard.NewClient(token)
+	c.BaseURL = config.Pinboard.APIURL
+	for _, d := range docs {
+		if len(d.added) == 0 {
+			continue
+		}
+		err := c.AddTags(ctx, d.post.Href, d.added)
+		if errors.Cause(err) == pinboard.ErrItemNotFound {
+			logger.Warn("retag skip",
+				zap.String("url", d.post.Href),
+				zap.String("err", err.Error()))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		logger.Info("retag",
+			zap.String("url", d.post.Href),
+			zap.Strings("tags", d.added))
+	}
+	return nil
+}
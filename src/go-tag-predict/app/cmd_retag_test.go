@@ -0,0 +1,48 @@
+package app
+
+import (
+	"fmt"
+	"go-tag-predict/webservice/pinboard"
+	"os"
+	"strconv"
+)
+
+func Example_foldOf() {
+	counts := make([]int, 5)
+	for i := 0; i < 1000; i++ {
+		counts[foldOf("http://example.com/"+strconv.Itoa(i), 1, 5)]++
+	}
+	fmt.Println(counts)
+	fmt.Println(foldOf("http://example.com/", 1, 5) == foldOf("http://example.com/", 1, 5))
+
+	// Output:
+	// [188 221 202 198 191]
+	// true
+}
+
+func Example_retagTargets() {
+	docs := []*retagDoc{
+		{post: &pinboard.Post{Href: "http://1"}, tags: nonEmptyTags([]string{""}), tokens: "1 2", fold: -1},
+		{post: &pinboard.Post{Href: "http://2"}, tags: []string{"go"}, tokens: "3 4", fold: 0},
+		{post: &pinboard.Post{Href: "http://3"}, tags: []string{"go", "web"}, tokens: "5", fold: 0},
+		{post: &pinboard.Post{Href: "http://4"}, tags: []string{"web"}, tokens: "", fold: 0},
+		{post: &pinboard.Post{Href: "http://5"}, tags: []string{"web"}, tokens: "6", fold: 1},
+	}
+	for fold := -1; fold < 2; fold++ {
+		for _, d := range retagTargets(docs, 2, fold) {
+			fmt.Println(fold, d.post.Href)
+		}
+	}
+
+	docs[0].added = addedTags(docs[0].tags, PredictResults{{Tag: "go"}, {Tag: "web"}})
+	docs[1].added = addedTags(docs[1].tags, PredictResults{{Tag: "go"}, {Tag: "golang"}})
+	docs[4].added = addedTags(docs[4].tags, PredictResults{{Tag: "web"}})
+	writeRetagDiff(os.Stdout, docs)
+
+	// Output:
+	// -1 http://1
+	// 0 http://2
+	// 1 http://5
+	// http://1		+go +web
+	// http://2	go	+golang
+}
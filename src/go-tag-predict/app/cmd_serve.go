@@ -0,0 +1,244 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+// RunServe : 分類APIサーバーのメイン関数
+//
+// 学習結果は起動時に一度だけロードし、ctxがキャンセルされるまでリクエストを受け付ける
+func RunServe(ctx context.Context, config *Config, logger *zap.Logger) error {
+	p, err := NewPredictor(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+
+	server := &http.Server{
+		Addr:         config.Serve.Listen,
+		Handler:      newServeHandler(p, config.Serve, logger),
+		ReadTimeout:  time.Duration(config.Serve.ReadTimeoutSeconds) * time.Second,
+		WriteTimeout: time.Duration(config.Serve.WriteTimeoutSeconds) * time.Second,
+		IdleTimeout:  time.Duration(config.Serve.IdleTimeoutSeconds) * time.Second,
+	}
+	c := make(chan error, 1)
+	go func() {
+		c <- server.ListenAndServe()
+	}()
+	logger.Info("listen", zap.String("addr", config.Serve.Listen))
+
+	select {
+	case err := <-c:
+		return errors.WithStack(err)
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// tagPredictor : APIサーバーから使う分類処理
+type tagPredictor interface {
+	PredictURL(ctx context.Context, rawurl string) (*PredictOutput, error)
+	PredictText(ctx context.Context, text string) (*PredictOutput, error)
+}
+
+// predictRequest : 分類APIのリクエスト
+// URLとTextのどちらか一方を指定する
+type predictRequest struct {
+	URL  string `json:"url,omitempty"`
+	Text string `json:"text,omitempty"`
+}
+
+// predictResponse : 分類APIのレスポンス
+type predictResponse struct {
+	URL     string         `json:"url,omitempty"`
+	Tags    PredictResults `json:"tags"`
+	OOVRate float64        `json:"oov_rate"`
+	Lang    string         `json:"lang,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type batchRequest struct {
+	Items []*predictRequest `json:"items"`
+}
+
+type batchResponse struct {
+	Results []*predictResponse `json:"results"`
+}
+
+type serveHandler struct {
+	p      tagPredictor
+	config *ServeConfig
+	logger *zap.Logger
+}
+
+func newServeHandler(p tagPredictor, config *ServeConfig, logger *zap.Logger) http.Handler {
+	h := &serveHandler{p: p, config: config, logger: logger}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/predict/url", h.handleURL)
+	mux.HandleFunc("/predict/text", h.handleText)
+	mux.HandleFunc("/predict/batch", h.handleBatch)
+	return mux
+}
+
+// GET /predict/url?url=... or POST /predict/url {"url": "..."}
+func (h *serveHandler) handleURL(w http.ResponseWriter, r *http.Request) {
+	req := &predictRequest{}
+	switch r.Method {
+	case http.MethodGet:
+		req.URL = r.URL.Query().Get("url")
+	case http.MethodPost:
+		if !h.decode(w, r, req) {
+			return
+		}
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, errors.New("GET or POST only"))
+		return
+	}
+	if req.URL == "" {
+		h.writeError(w, http.StatusBadRequest, errors.New("url is empty"))
+		return
+	}
+	res := h.predict(r.Context(), &predictRequest{URL: req.URL})
+	if res.Error != "" {
+		h.writeJSON(w, http.StatusBadGateway, res)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, res)
+}
+
+// POST /predict/text {"text": "..."}
+func (h *serveHandler) handleText(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.writeError(w, http.StatusMethodNotAllowed, errors.New("POST only"))
+		return
+	}
+	req := &predictRequest{}
+	if !h.decode(w, r, req) {
+		return
+	}
+	if req.Text == "" {
+		h.writeError(w, http.StatusBadRequest, errors.New("text is empty"))
+		return
+	}
+	res := h.predict(r.Context(), &predictRequest{Text: req.Text})
+	if res.Error != "" {
+		h.writeJSON(w, http.StatusInternalServerError, res)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, res)
+}
+
+// POST /predict/batch {"items": [{"url": "..."}, {"text": "..."}]}
+//
+// 個々の分類に失敗しても、そのitemのerrorに記録して全体は200を返す
+func (h *serveHandler) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.writeError(w, http.StatusMethodNotAllowed, errors.New("POST only"))
+		return
+	}
+	req := &batchRequest{}
+	if !h.decode(w, r, req) {
+		return
+	}
+	if h.config.MaxBatchSize > 0 && len(req.Items) > h.config.MaxBatchSize {
+		h.writeError(w, http.StatusRequestEntityTooLarge, errors.Errorf("too many items (max %d)", h.config.MaxBatchSize))
+		return
+	}
+	res := &batchResponse{Results: make([]*predictResponse, len(req.Items))}
+	wg := sync.WaitGroup{}
+	limitter := make(chan struct{}, max(1, h.config.ParallelsCount)) // 同時実行数の制御
+	for i, item := range req.Items {
+		if item == nil {
+			item = &predictRequest{}
+		}
+		wg.Add(1)
+		limitter <- struct{}{}
+		go func(i int, item *predictRequest) {
+			defer func() {
+				<-limitter
+				wg.Done()
+			}()
+			res.Results[i] = h.predict(r.Context(), item)
+		}(i, item)
+	}
+	wg.Wait()
+	h.writeJSON(w, http.StatusOK, res)
+}
+
+func (h *serveHandler) predict(ctx context.Context, req *predictRequest) *predictResponse {
+	res := &predictResponse{URL: req.URL, Tags: PredictResults{}}
+	var out *PredictOutput
+	var err error
+	if req.URL != "" {
+		out, err = h.p.PredictURL(ctx, req.URL)
+	} else if req.Text != "" {
+		out, err = h.p.PredictText(ctx, req.Text)
+	} else {
+		err = errors.New("url or text is required")
+	}
+	if err != nil {
+		h.logger.Debug("predict error",
+			zap.String("url", req.URL),
+			zap.String("err", err.Error()))
+		res.Error = err.Error()
+		return res
+	}
+	if out.Tags != nil {
+		res.Tags = out.Tags
+	}
+	res.OOVRate = out.OOVRate
+	res.Lang = out.Lang
+	return res
+}
+
+// decode : リクエストボディのJSONを読み込む
+// max_body_bytesを超えるボディは、最後まで読まずに413を返す
+func (h *serveHandler) decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	defer r.Body.Close()
+	maxBytes := h.config.GetMaxBodyBytes()
+	b, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBytes+1))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, errors.WithStack(err))
+		return false
+	}
+	if int64(len(b)) > maxBytes {
+		h.writeError(w, http.StatusRequestEntityTooLarge, errors.Errorf("request body too large (max %d bytes)", maxBytes))
+		return false
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		h.writeError(w, http.StatusBadRequest, errors.Wrap(err, "bad json"))
+		return false
+	}
+	return true
+}
+
+func (h *serveHandler) writeError(w http.ResponseWriter, status int, err error) {
+	h.writeJSON(w, status, &errorResponse{Error: err.Error()})
+}
+
+func (h *serveHandler) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.logger.Warn("write error", zap.String("err", err.Error()))
+	}
+}
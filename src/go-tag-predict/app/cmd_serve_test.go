@@ -0,0 +1,58 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type fakePredictor struct{}
+
+func (p *fakePredictor) PredictURL(ctx context.Context, rawurl string) (*PredictOutput, error) {
+	if strings.HasPrefix(rawurl, "http://error") {
+		return nil, errors.New("404 Not Found")
+	}
+	return &PredictOutput{Tags: PredictResults{&PredictResult{Tag: "url", Probability: 0.5}}, OOVRate: 0.125, Lang: "ja"}, nil
+}
+func (p *fakePredictor) PredictText(ctx context.Context, text string) (*PredictOutput, error) {
+	return &PredictOutput{Tags: PredictResults{&PredictResult{Tag: text, Probability: 0.25}}}, nil
+}
+
+func Example_serveHandler() {
+	ts := httptest.NewServer(newServeHandler(&fakePredictor{}, &ServeConfig{ParallelsCount: 2, MaxBatchSize: 3, MaxBodyBytes: 256}, zap.NewNop()))
+	defer ts.Close()
+
+	show := func(res *http.Response, err error) {
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer res.Body.Close()
+		body, _ := ioutil.ReadAll(res.Body)
+		fmt.Print(res.StatusCode, " ", string(body))
+	}
+	show(http.Get(ts.URL + "/predict/url?url=http://1"))
+	show(http.Get(ts.URL + "/predict/url?url=http://error"))
+	show(http.Post(ts.URL+"/predict/text", "application/json", strings.NewReader(`{"text":"golang"}`)))
+	show(http.Post(ts.URL+"/predict/text", "application/json", strings.NewReader(`{`)))
+	show(http.Get(ts.URL + "/predict/text"))
+	show(http.Post(ts.URL+"/predict/text", "application/json", strings.NewReader(`{"text":"`+strings.Repeat("a", 256)+`"}`)))
+	show(http.Post(ts.URL+"/predict/batch", "application/json", strings.NewReader(`{"items":[{"url":"http://1"},{"text":"a"},{"url":"http://error"}]}`)))
+	show(http.Post(ts.URL+"/predict/batch", "application/json", strings.NewReader(`{"items":[{},{},{},{}]}`)))
+
+	// Output:
+	// 200 {"url":"http://1","tags":[{"tag":"url","probability":0.5}],"oov_rate":0.125,"lang":"ja"}
+	// 502 {"url":"http://error","tags":[],"oov_rate":0,"error":"404 Not Found"}
+	// 200 {"tags":[{"tag":"golang","probability":0.25}],"oov_rate":0}
+	// 400 {"error":"bad json: unexpected end of JSON input"}
+	// 405 {"error":"POST only"}
+	// 413 {"error":"request body too large (max 256 bytes)"}
+	// 200 {"results":[{"url":"http://1","tags":[{"tag":"url","probability":0.5}],"oov_rate":0.125,"lang":"ja"},{"tags":[{"tag":"a","probability":0.25}],"oov_rate":0},{"url":"http://error","tags":[],"oov_rate":0,"error":"404 Not Found"}]}
+	// 413 {"error":"too many items (max 3)"}
+}
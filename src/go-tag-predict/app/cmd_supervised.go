@@ -3,9 +3,12 @@ package app
 import (
 	"bufio"
 	"context"
+	"crypto/sha1"
+	"encoding/binary"
 	"go-tag-predict/asyncwriter"
 	"go-tag-predict/lambda"
 	"go-tag-predict/webservice/pinboard"
+	"io"
 	"os"
 	"os/exec"
 	"runtime"
@@ -33,24 +36,32 @@ func RunSupervised(ctx context.Context, config *Config, logger *zap.Logger) erro
 	return nil
 }
 func createSupervisedInput(ctx context.Context, config *Config, logger *zap.Logger) error {
-	itr, err := pinboard.LoadFile(config.Supervised.LearningSourceFilePath)
+	if err := syncLearningSource(ctx, config, logger); err != nil {
+		return err
+	}
+	tn, err := loadTagNormalizer(config, logger)
 	if err != nil {
 		return err
 	}
+	itr, err := loadLearningSource(config)
+	if err != nil {
+		return err
+	}
+	defer itr.Close()
 	err = os.MkdirAll(config.TmpDirPath, 0700)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	f, err := os.Create(config.GetSupervisedSourcePath())
+	sw, err := newSupervisedWriters(ctx, config)
 	if err != nil {
-		return errors.WithStack(err)
+		return err
 	}
-	defer f.Close()
-
-	aw := asyncwriter.NewWriter(ctx, bufio.NewWriterSize(f, config.Supervised.WriterBufferSize), config.Supervised.WriterQueueCount)
+	defer sw.closeFiles()
 
-	t := NewTagID()
+	// ラベルと本文の単語は別々にIDを振る
+	labels := NewTagID()
+	vocab := NewTagID()
 
 	eg, ctx := errgroup.WithContext(ctx)
 	limitter := make(chan struct{}, max(0, config.Supervised.ParallelsCount-1)) // 同時実行数の制御
@@ -72,7 +83,7 @@ func createSupervisedInput(ctx context.Context, config *Config, logger *zap.Logg
 				defer func() {
 					<-limitter
 				}()
-				return procPost(ctx, config, logger, t, aw, post)
+				return procPost(ctx, config, logger, tn, labels, vocab, sw, post)
 			})
 		}(post)
 		i++
@@ -90,15 +101,126 @@ func createSupervisedInput(ctx context.Context, config *Config, logger *zap.Logg
 	}
 	// fmt.Println(i)
 
-	aw.Close()
-	<-aw.Done()
+	if err := sw.close(); err != nil {
+		return err
+	}
+	sw.closeFiles()
+	if err := pruneVocab(config, logger, vocab); err != nil {
+		return err
+	}
+
+	if err := serializeTagIDFile(config.GetTagIDPath(), labels); err != nil {
+		return err
+	}
+	if err := serializeTagIDFile(config.GetVocabIDPath(), vocab); err != nil {
+		return err
+	}
+	return saveTokenFilterFingerprint(config.GetTokenFilterPath(), config.TokenFilter, config.Language)
+}
+
+// pruneVocab : unk_min_countを指定した場合は、出現回数の少ない単語をUnknownTagにまとめて、学習/検証/テスト用データのIDを書き換える
+// 学習データに<unk>が含まれるので、分類時にoov_policy = "unk"で辞書にない単語を<unk>に置き換えると、学習した<unk>のベクトルが使われる
+func pruneVocab(config *Config, logger *zap.Logger, vocab TagID) error {
+	if config.Supervised.UnkMinCount <= 0 {
+		return nil
+	}
+	remap := vocab.Prune(config.Supervised.UnkMinCount)
+	logger.Info("vocab",
+		zap.Int("unk_min_count", config.Supervised.UnkMinCount),
+		zap.Int("pruned", len(remap)),
+		zap.Int("unk_count", vocab.Count(UnknownTag)))
+	if len(remap) == 0 {
+		return nil
+	}
+	for _, filePath := range []string{config.GetSupervisedSourcePath(), config.GetValidationSourcePath(), config.GetTestSourcePath()} {
+		if err := rewriteTokenFile(filePath, remap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	if f, err := os.Create(config.GetTagIDPath()); err == nil {
+// rewriteTokenFile : fastTextの学習データの本文の単語IDを、remapに従って書き換える
+func rewriteTokenFile(filePath string, remap map[int]int) error {
+	return writeFileAtomic(filePath, func(w io.Writer) error {
+		f, err := os.Open(filePath)
+		if err != nil {
+			return errors.WithStack(err)
+		}
 		defer f.Close()
-		t.Serialize(f)
-	} else {
+		bw := bufio.NewWriterSize(w, 1024*100)
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 1024*1024), 1024*1024*100)
+		for scanner.Scan() {
+			bw.WriteString(remapTokens(scanner.Text(), remap))
+			bw.WriteString("\n")
+		}
+		if err := scanner.Err(); err != nil {
+			return errors.WithStack(err)
+		}
+		return errors.WithStack(bw.Flush())
+	})
+}
+
+// remapTokens : 1行分の" , "より後ろの単語IDを書き換える
+func remapTokens(line string, remap map[int]int) string {
+	i := strings.Index(line, " , ")
+	if i < 0 {
+		return line
+	}
+	tokens := strings.Fields(line[i+3:])
+	for j, token := range tokens {
+		id, err := strconv.Atoi(token)
+		if err != nil {
+			continue
+		}
+		if to, ok := remap[id]; ok {
+			tokens[j] = strconv.Itoa(to)
+		}
+	}
+	return line[:i+3] + strings.Join(tokens, " ")
+}
+
+// loadTagNormalizer : [tags]の設定でタグを正規化する
+// min_tag_count, max_tagsを指定した場合は、学習ソースを1度読んでタグの出現回数を数え、学習に使うタグを決める
+// pinboardの場合は、同期済みのミラーファイルを読む
+func loadTagNormalizer(config *Config, logger *zap.Logger) (*TagNormalizer, error) {
+	tn, err := NewTagNormalizer(config.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if !config.Tags.needsCount() {
+		return tn, nil
+	}
+	itr, err := loadLearningSource(config)
+	if err != nil {
+		return nil, err
+	}
+	defer itr.Close()
+	counts := make(map[string]int, 1024)
+	for {
+		post, err := itr.Next()
+		if err != nil {
+			return nil, err
+		}
+		if post == nil {
+			break
+		}
+		countTags(counts, tn.Normalize(post.Tags))
+	}
+	n := tn.Limit(counts, config.Tags.MinTagCount, config.Tags.MaxTags)
+	logger.Info("tags",
+		zap.Int("tags", len(counts)),
+		zap.Int("kept", n))
+	return tn, nil
+}
+func serializeTagIDFile(filePath string, t TagID) error {
+	f, err := os.Create(filePath)
+	if err != nil {
 		return errors.WithStack(err)
 	}
+	defer f.Close()
+	t.Serialize(f)
 	return nil
 }
 func supervised(ctx context.Context, config *Config) error {
@@ -108,20 +230,44 @@ func supervised(ctx context.Context, config *Config) error {
 	cmd := exec.CommandContext(
 		ctx,
 		config.Fasttext.Command,
-		lambda.MapString(config.Fasttext.SupervisedArgs, func(s string) string {
-			if s == "{MODEL_PATH}" {
-				return modelPath
-			}
-			if s == "{DATA_PATH}" {
-				return inputPath
-			}
-			return s
-		})...)
+		supervisedCommandArgs(config.Fasttext.SupervisedArgs, modelPath, inputPath)...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
-func procPost(ctx context.Context, config *Config, logger *zap.Logger, t TagID, aw *asyncwriter.Writer, post *pinboard.Post) error {
+
+// supervisedCommandArgs : supervised_argsの{MODEL_PATH}と{DATA_PATH}を置き換える
+func supervisedCommandArgs(args []string, modelPath string, inputPath string) []string {
+	return lambda.MapString(args, func(s string) string {
+		if s == "{MODEL_PATH}" {
+			return modelPath
+		}
+		if s == "{DATA_PATH}" {
+			return inputPath
+		}
+		return s
+	})
+}
+func procPost(ctx context.Context, config *Config, logger *zap.Logger, tn *TagNormalizer, labels TagID, vocab TagID, sw *supervisedWriters, post *pinboard.Post) error {
+	tokens, err := loadPostTokens(ctx, config, logger, post)
+	if err != nil || tokens == nil {
+		return err
+	}
+	// 学習に使わないタグなしのブックマークの単語は、vocabに登録/カウントしない
+	if tags := tn.Normalize(post.Tags); len(tags) > 0 {
+		tokens = lambda.MapIntString(vocab.GetIDs(tokens), strconv.Itoa)
+		sw.write(post.Href, labels.GetIDs(tags), strings.Join(tokens, " "))
+	}
+	logger.Info("finish",
+		zap.String("url", post.Href),
+		zap.Int("goroutines", runtime.NumGoroutine()),
+	)
+	return nil
+}
+
+// loadPostTokens : ブックマークのページを取得して分かち書きする
+// ページの取得に失敗した場合や本文が短い場合は、学習に使わないのでnilを返す
+func loadPostTokens(ctx context.Context, config *Config, logger *zap.Logger, post *pinboard.Post) ([]string, error) {
 	logger.Debug("begin",
 		zap.String("url", post.Href),
 		zap.Int("goroutines", runtime.NumGoroutine()),
@@ -138,10 +284,10 @@ func procPost(ctx context.Context, config *Config, logger *zap.Logger, t TagID,
 			zap.String("err", err.Error()),
 			zap.Int("goroutines", runtime.NumGoroutine()),
 		)
-		return nil // ページの取得に失敗しても全体の処理を継続する
+		return nil, nil // ページの取得に失敗しても全体の処理を継続する
 	}
 	if len(content) < 128 { // 本文が短いデータを除去
-		return nil
+		return nil, nil
 	}
 	tokens, err := tokenizeWebContent(ctx, config, post, content)
 	logger.Debug("tokenize",
@@ -153,23 +299,107 @@ func procPost(ctx context.Context, config *Config, logger *zap.Logger, t TagID,
 			zap.String("url", post.Href),
 			zap.Int("goroutines", runtime.NumGoroutine()),
 		)
-		return err
+		return nil, err
 	}
-	// TODO: 句読点などのノイズを除去
+	return tokens, nil
+}
 
-	tokens = lambda.MapIntString(t.GetIDs(tokens), strconv.Itoa)
+// 学習データの分割先
+const (
+	splitTrain = iota
+	splitValidation
+	splitTest
+)
 
-	strTokens := strings.Join(tokens, " ")
-	for _, tag := range post.Tags {
-		aw.WriteString(
-			strings.Join([]string{"__label__", strconv.Itoa(t.GetID(tag)), " , ", strTokens, "\n"}, ""),
-		)
+// splitOf : URLとseedのハッシュ値で、学習/検証/テストのどれに使うかを決める
+// 同じURL, seed, 比率であれば、何度実行しても同じ分割になる
+func splitOf(href string, seed int64, validationRatio float64, testRatio float64) int {
+	sum := sha1.Sum([]byte(strconv.FormatInt(seed, 10) + "\t" + href))
+	x := float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53) // [0, 1)
+	if x < testRatio {
+		return splitTest
 	}
-	logger.Info("finish",
-		zap.String("url", post.Href),
-		zap.Int("goroutines", runtime.NumGoroutine()),
-	)
-	return nil
+	if x < testRatio+validationRatio {
+		return splitValidation
+	}
+	return splitTrain
+}
+
+// supervisedWriters : 学習(input.txt)/検証(valid.txt)/テスト(test.txt)用データの出力先
+type supervisedWriters struct {
+	config  *SupervisedConfig
+	files   []*os.File
+	writers []*asyncwriter.Writer
+}
+
+func newSupervisedWriters(ctx context.Context, config *Config) (*supervisedWriters, error) {
+	sw := &supervisedWriters{config: config.Supervised}
+	for _, filePath := range []string{config.GetSupervisedSourcePath(), config.GetValidationSourcePath(), config.GetTestSourcePath()} {
+		f, err := os.Create(filePath)
+		if err != nil {
+			sw.closeFiles()
+			return nil, errors.WithStack(err)
+		}
+		sw.files = append(sw.files, f)
+		sw.writers = append(sw.writers, asyncwriter.NewWriter(ctx, bufio.NewWriterSize(f, config.Supervised.WriterBufferSize), config.Supervised.WriterQueueCount))
+	}
+	return sw, nil
+}
+
+// write : 1文書分の学習データを出力する
+// 検証/テスト用データは評価しやすいように、label_modeに関わらずマルチラベル形式で出力する
+func (sw *supervisedWriters) write(href string, labelIDs []int, tokens string) {
+	split := splitOf(href, sw.config.SplitSeed, sw.config.ValidationRatio, sw.config.TestRatio)
+	mode := sw.config.LabelMode
+	if split != splitTrain {
+		mode = LabelModeMulti
+	}
+	sw.writers[split].WriteString(supervisedLines(labelIDs, tokens, mode))
+}
+
+// close : 書き込み完了まで待機して、最初に発生した書き込みエラーを返す
+func (sw *supervisedWriters) close() error {
+	var err error
+	for _, aw := range sw.writers {
+		aw.Close()
+		<-aw.Done()
+		if e := aw.Err(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (sw *supervisedWriters) closeFiles() {
+	for _, f := range sw.files {
+		f.Close()
+	}
+}
+
+// ラベルの書き出し方
+const (
+	// LabelModeMulti : 1行に全てのラベルを並べる (fastTextのマルチラベル形式)
+	LabelModeMulti = "multi"
+	// LabelModeExpand : ラベルごとに同じ文書を1行ずつ書き出す
+	LabelModeExpand = "expand"
+)
+
+// supervisedLines : fastText学習用の行を生成する
+// Example:
+//   supervisedLines([]int{1, 2}, "3 4", "multi")  // "__label__1 __label__2 , 3 4\n"
+//   supervisedLines([]int{1, 2}, "3 4", "expand") // "__label__1 , 3 4\n__label__2 , 3 4\n"
+func supervisedLines(labelIDs []int, tokens string, mode string) string {
+	labels := lambda.MapIntString(labelIDs, func(id int) string {
+		return "__label__" + strconv.Itoa(id)
+	})
+	if mode == LabelModeExpand {
+		lines := make([]string, len(labels))
+		for i, label := range labels {
+			lines[i] = label + " , " + tokens + "\n"
+		}
+		return strings.Join(lines, "")
+	}
+	return strings.Join(labels, " ") + " , " + tokens + "\n"
 }
 func tokenizeWebContent(ctx context.Context, config *Config, post *pinboard.Post, content string) ([]string, error) {
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
@@ -181,7 +411,11 @@ func tokenizeWebContent(ctx context.Context, config *Config, post *pinboard.Post
 	}
 	c := make(chan resultSet, 0)
 	go func() {
-		if v, err := Tokenize(ctx, config, post.Title+"\n"+content); err != nil {
+		text := post.Title + "\n" + content
+		if config.Supervised.UseExtended && post.Extended != "" {
+			text = post.Title + "\n" + post.Extended + "\n" + content
+		}
+		if v, err := Tokenize(ctx, config, text); err != nil {
 			c <- resultSet{nil, errors.Wrap(err, "tokenize error: \n"+post.Title+"\n"+post.Href+"\nSize:"+strconv.Itoa(len(content)))}
 		} else {
 			c <- resultSet{v, nil}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"fmt"
+)
+
+func Example_supervisedLines() {
+	fmt.Print(supervisedLines([]int{1, 2, 5}, "3 4", LabelModeMulti))
+	fmt.Print(supervisedLines([]int{1, 2, 5}, "3 4", LabelModeExpand))
+	// Output:
+	// __label__1 __label__2 __label__5 , 3 4
+	// __label__1 , 3 4
+	// __label__2 , 3 4
+	// __label__5 , 3 4
+}
+
+func Example_remapTokens() {
+	vocab := NewTagID()
+	vocab.GetIDs([]string{"go", "web", "go", "rare", "go", "web"})
+	remap := vocab.Prune(2)
+	fmt.Println(remap)
+	fmt.Println(vocab.Lookup(UnknownTag))
+	fmt.Println(vocab.Count(UnknownTag))
+	fmt.Println(remapTokens("__label__1 __label__2 , 1 2 3 1", remap))
+	fmt.Println(remapTokens("no separator 3", remap))
+	// Output:
+	// map[3:0]
+	// 0 true
+	// 1
+	// __label__1 __label__2 , 1 2 0 1
+	// no separator 3
+}
+
+func Example_splitOf() {
+	counts := make([]int, 3)
+	for i := 0; i < 1000; i++ {
+		counts[splitOf(fmt.Sprintf("https://example.com/%d", i), 1, 0.1, 0.2)]++
+	}
+	fmt.Println(counts)
+	// 同じURL, seedなら同じ分割になる
+	fmt.Println(splitOf("https://example.com/1", 1, 0.1, 0.2) == splitOf("https://example.com/1", 1, 0.1, 0.2))
+	fmt.Println(splitOf("https://example.com/1", 1, 0, 0))
+	// Output:
+	// [699 94 207]
+	// true
+	// 0
+}
@@ -0,0 +1,299 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"go-tag-predict/lambda"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+// タグの変更方法
+const (
+	// TagsCommandRename : tags rename OLD NEW
+	TagsCommandRename = "rename"
+	// TagsCommandMerge : tags merge A B ... into C
+	TagsCommandMerge = "merge"
+	// TagsCommandDrop : tags drop X ...
+	TagsCommandDrop = "drop"
+)
+
+// TagsMigration : タグの変更内容 (移行ログの1行)
+type TagsMigration struct {
+	Time    string   `json:"time"`
+	Command string   `json:"command"`
+	From    []string `json:"from"`
+	To      string   `json:"to,omitempty"`
+	// Lines : ラベルを書き換えた行数 (input.txt, valid.txt, test.txtの合計)
+	Lines int `json:"lines"`
+	// DroppedLines : ラベルがなくなった or 重複したので削除した行数
+	DroppedLines int `json:"dropped_lines"`
+}
+
+// RunTags : タグの変更メイン関数
+//
+// ページを取得し直さずに、tagid.txtと学習/検証/テスト用データの__label__を書き換える
+// 変更内容は移行ログ(tags_migration.jsonl)に追記する
+// retrainの場合は、書き換えた学習データで学習し直す。しない場合、既存のモデルは変更前のタグのまま
+func RunTags(ctx context.Context, config *Config, logger *zap.Logger, args []string, retrain bool) error {
+	m, err := parseTagsArgs(args)
+	if err != nil {
+		return err
+	}
+	tn, err := NewTagNormalizer(config.Tags)
+	if err != nil {
+		return err
+	}
+	for i, tag := range m.From {
+		m.From[i] = tn.fold(tag)
+	}
+	m.To = tn.fold(m.To)
+
+	f, err := os.Open(config.GetTagIDPath())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	labels, err := LoadTagID(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	// renameで既存のタグにまとめると元に戻せないので、mergeを明示させる
+	if _, ok := labels.Lookup(m.To); ok && m.Command == TagsCommandRename && m.From[0] != m.To {
+		return errors.Errorf("tag %q already exists. use tags merge %s into %s", m.To, m.From[0], m.To)
+	}
+	var remap map[int]int
+	if m.Command == TagsCommandDrop {
+		remap, err = labels.Drop(m.From)
+	} else {
+		remap, err = labels.Merge(m.From, m.To)
+	}
+	if err != nil {
+		return err
+	}
+
+	dups := map[int]int{}
+	for _, file := range []struct {
+		path   string
+		expand bool
+	}{
+		{config.GetSupervisedSourcePath(), config.Supervised.LabelMode == LabelModeExpand},
+		{config.GetValidationSourcePath(), false},
+		{config.GetTestSourcePath(), false},
+	} {
+		if _, err := os.Stat(file.path); os.IsNotExist(err) || len(remap) == 0 {
+			continue
+		}
+		lines, dropped, err := rewriteLabelFile(file.path, remap, file.expand, dups)
+		if err != nil {
+			return err
+		}
+		m.Lines += lines
+		m.DroppedLines += dropped
+	}
+	if id, ok := labels.Lookup(m.To); ok {
+		labels.SetCount(m.To, labels.Count(m.To)-dups[id])
+	}
+	err = writeFileAtomic(config.GetTagIDPath(), func(w io.Writer) error {
+		labels.Serialize(w)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	m.Time = time.Now().Format(time.RFC3339)
+	if err := appendTagsMigrationLog(config.GetTagsMigrationLogPath(), m); err != nil {
+		return err
+	}
+	logger.Info("tags",
+		zap.String("command", m.Command),
+		zap.Strings("from", m.From),
+		zap.String("to", m.To),
+		zap.Int("lines", m.Lines),
+		zap.Int("dropped_lines", m.DroppedLines))
+	// 設定ファイルはコメントを含むので書き換えずに、次回のsupervisedで同じ変更を適用するための設定を表示する
+	logger.Warn("add to the config file to apply the same change in the next supervised",
+		zap.String("toml", tagsConfigHint(m)))
+
+	if !retrain {
+		logger.Warn("model is not updated. run with -retrain or supervised to use the new tags")
+		return nil
+	}
+	return supervised(ctx, config)
+}
+
+// tagsConfigHint : 次回の学習でも同じ変更を適用するための、[tags]の設定(TOMLの断片)
+// rename, mergeは[tags.aliases]に、dropは[tags] denyに追加する
+func tagsConfigHint(m *TagsMigration) string {
+	if m.Command == TagsCommandDrop {
+		return "[tags]\ndeny = [" + strings.Join(lambda.MapString(m.From, strconv.Quote), ", ") + "]\n"
+	}
+	lines := []string{"[tags.aliases]"}
+	for _, from := range m.From {
+		if from != m.To {
+			lines = append(lines, strconv.Quote(from)+" = "+strconv.Quote(m.To))
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// parseTagsArgs : tagsコマンドの引数を解釈する
+// Example:
+//   parseTagsArgs([]string{"rename", "golang", "go"})
+//   parseTagsArgs([]string{"merge", "golang", "Go", "into", "go"})
+//   parseTagsArgs([]string{"drop", "toread", "via:twitter"})
+func parseTagsArgs(args []string) (*TagsMigration, error) {
+	if len(args) == 0 {
+		return nil, errors.New("usage: tags rename OLD NEW | tags merge A B ... into C | tags drop X ...")
+	}
+	m := &TagsMigration{Command: args[0]}
+	args = args[1:]
+	switch m.Command {
+	case TagsCommandRename:
+		if len(args) != 2 {
+			return nil, errors.New("usage: tags rename OLD NEW")
+		}
+		m.From, m.To = args[:1], args[1]
+	case TagsCommandMerge:
+		if len(args) < 3 || args[len(args)-2] != "into" {
+			return nil, errors.New("usage: tags merge A B ... into C")
+		}
+		m.From, m.To = args[:len(args)-2], args[len(args)-1]
+	case TagsCommandDrop:
+		if len(args) == 0 {
+			return nil, errors.New("usage: tags drop X ...")
+		}
+		m.From = args
+	default:
+		return nil, errors.Errorf("bad tags command: %q (rename, merge or drop)", m.Command)
+	}
+	return m, nil
+}
+
+// rewriteLabelFile : fastTextの学習データの__label__を、remapに従って書き換える
+// 変更後のIDがUnknownIDのラベルは削除し、ラベルがなくなった行は削除する
+// まとめたことで重複したラベル(expandの場合は、同じ文書の重複した行)は削除して、削除した数をdupsに加算する
+// 書き換えた行数と、削除した行数を返す
+func rewriteLabelFile(filePath string, remap map[int]int, expand bool, dups map[int]int) (int, int, error) {
+	lines, dropped := 0, 0
+	err := writeFileAtomic(filePath, func(w io.Writer) error {
+		f, err := os.Open(filePath)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer f.Close()
+		bw := bufio.NewWriterSize(w, 1024*100)
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 1024*1024), 1024*1024*100)
+		prevOrig, prev := "", ""
+		for scanner.Scan() {
+			orig := scanner.Text()
+			line, changed := remapLabels(orig, remap, dups)
+			switch {
+			case line == "":
+				dropped++
+				continue
+			case expand && changed && line == prev && orig != prevOrig:
+				dups[firstLabelID(line)]++
+				dropped++
+				continue
+			case changed:
+				lines++
+			}
+			prevOrig, prev = orig, line
+			bw.WriteString(line)
+			bw.WriteString("\n")
+		}
+		if err := scanner.Err(); err != nil {
+			return errors.WithStack(err)
+		}
+		return errors.WithStack(bw.Flush())
+	})
+	return lines, dropped, err
+}
+
+// remapLabels : 1行分のラベルを書き換える。ラベルがなくなった場合は空文字を返す
+func remapLabels(line string, remap map[int]int, dups map[int]int) (string, bool) {
+	i := strings.Index(line, " , ")
+	if i < 0 {
+		return line, false
+	}
+	labels := strings.Fields(line[:i])
+	res := make([]string, 0, len(labels))
+	changed := false
+	for _, label := range labels {
+		id, err := strconv.Atoi(strings.TrimPrefix(label, "__label__"))
+		if err != nil {
+			res = append(res, label)
+			continue
+		}
+		if to, ok := remap[id]; ok {
+			changed = true
+			if to == UnknownID {
+				continue
+			}
+			label = "__label__" + strconv.Itoa(to)
+			if containsString(res, label) {
+				dups[to]++
+				continue
+			}
+		}
+		res = append(res, label)
+	}
+	if !changed {
+		return line, false
+	}
+	if len(res) == 0 {
+		return "", true
+	}
+	return strings.Join(res, " ") + line[i:], true
+}
+
+func firstLabelID(line string) int {
+	id, _ := strconv.Atoi(strings.TrimPrefix(strings.Fields(line)[0], "__label__"))
+	return id
+}
+
+// writeFileAtomic : 書き込み途中で中断しても壊れないように、一時ファイルに書いてから置き換える
+func writeFileAtomic(filePath string, write func(w io.Writer) error) error {
+	f, err := ioutil.TempFile(filepath.Dir(filePath), filepath.Base(filePath)+".tmp")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := write(f); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(f.Name(), filePath))
+}
+
+// appendTagsMigrationLog : 移行ログにJSON Linesで追記する
+func appendTagsMigrationLog(filePath string, m *TagsMigration) error {
+	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+	b, err := json.Marshal(m)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(f.Close())
+}
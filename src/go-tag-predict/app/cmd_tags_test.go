@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+func Example_parseTagsArgs() {
+	for _, args := range [][]string{
+		{"rename", "golang", "go"},
+		{"merge", "golang", "Go", "into", "go"},
+		{"drop", "toread", "via:twitter"},
+		{"merge", "golang", "go"},
+		{"delete", "go"},
+	} {
+		m, err := parseTagsArgs(args)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Printf("%s %v %q\n", m.Command, m.From, m.To)
+	}
+
+	// Output:
+	// rename [golang] "go"
+	// merge [golang Go] "go"
+	// drop [toread via:twitter] ""
+	// usage: tags merge A B ... into C
+	// bad tags command: "delete" (rename, merge or drop)
+}
+
+func ExampleRunTags() {
+	dir, _ := ioutil.TempDir("", "tags")
+	defer os.RemoveAll(dir)
+	config := NewConfig()
+	config.TmpDirPath = dir
+	config.Supervised = &SupervisedConfig{LabelMode: LabelModeExpand}
+	config.Tags = &TagsConfig{CaseFold: true}
+	ioutil.WriteFile(config.GetTagIDPath(), []byte("1\tgo\t2\n2\tgolang\t3\n3\ttoread\t2\n4\tweb\t1\n"), 0600)
+	ioutil.WriteFile(config.GetSupervisedSourcePath(), []byte("__label__1 , 1 2\n__label__2 , 1 2\n__label__3 , 3\n__label__2 , 4\n__label__4 , 4\n"), 0600)
+	ioutil.WriteFile(config.GetValidationSourcePath(), []byte("__label__1 __label__2 __label__3 , 5\n"), 0600)
+	ioutil.WriteFile(config.GetTestSourcePath(), []byte(""), 0600)
+	printFile := func(filePath string) {
+		b, _ := ioutil.ReadFile(filePath)
+		fmt.Print(path.Base(filePath), "\n", string(b))
+	}
+
+	ctx := context.Background()
+	logger := zap.NewNop()
+	fmt.Println(RunTags(ctx, config, logger, []string{"merge", "golang", "Go", "into", "go"}, false))
+	fmt.Println(RunTags(ctx, config, logger, []string{"drop", "toread"}, false))
+	fmt.Println(RunTags(ctx, config, logger, []string{"rename", "web", "www"}, false))
+	fmt.Println(RunTags(ctx, config, logger, []string{"rename", "go", "WWW"}, false))
+	fmt.Println(RunTags(ctx, config, logger, []string{"drop", "unknown"}, false))
+	printFile(config.GetTagIDPath())
+	printFile(config.GetSupervisedSourcePath())
+	printFile(config.GetValidationSourcePath())
+
+	b, _ := ioutil.ReadFile(config.GetTagsMigrationLogPath())
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		m := &TagsMigration{}
+		json.Unmarshal([]byte(line), m)
+		fmt.Printf("%s %v %q %d %d\n", m.Command, m.From, m.To, m.Lines, m.DroppedLines)
+	}
+
+	// Output:
+	// <nil>
+	// <nil>
+	// <nil>
+	// tag "www" already exists. use tags merge go into www
+	// unknown tag: "unknown"
+	// tagid.txt
+	// 1	go	3
+	// 4	www	1
+	// input.txt
+	// __label__1 , 1 2
+	// __label__1 , 4
+	// __label__4 , 4
+	// valid.txt
+	// __label__1 , 5
+	// merge [golang go] "go" 2 1
+	// drop [toread] "" 1 1
+	// rename [web] "www" 0 0
+}
+
+func Example_tagsConfigHint() {
+	fmt.Print(tagsConfigHint(&TagsMigration{Command: TagsCommandRename, From: []string{"golang"}, To: "go"}))
+	fmt.Print(tagsConfigHint(&TagsMigration{Command: TagsCommandMerge, From: []string{"golang", "go", "go lang"}, To: "go"}))
+	fmt.Print(tagsConfigHint(&TagsMigration{Command: TagsCommandDrop, From: []string{"toread", "via:twitter"}}))
+	// Output:
+	// [tags.aliases]
+	// "golang" = "go"
+	// [tags.aliases]
+	// "golang" = "go"
+	// "go lang" = "go"
+	// [tags]
+	// deny = ["toread", "via:twitter"]
+}
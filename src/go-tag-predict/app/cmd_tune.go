@@ -0,0 +1,504 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-tag-predict/fasttext"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+// ハイパーパラメーター探索の方法
+const (
+	// TuneMethodGrid : 全ての組み合わせを試す
+	TuneMethodGrid = "grid"
+	// TuneMethodRandom : 組み合わせからtrials個をランダムに選んで試す
+	TuneMethodRandom = "random"
+	// TuneMethodHalving : epochを増やしながら、成績の良い組み合わせだけを残していく (successive halving)
+	TuneMethodHalving = "halving"
+	// TuneMethodAutotune : fastTextの-autotune-validationを使う
+	TuneMethodAutotune = "autotune"
+)
+
+// ハイパーパラメーター探索で最大化する指標 (検証用データでの全体の値)
+const (
+	TuneMetricF1        = "f1"
+	TuneMetricPrecision = "precision"
+	TuneMetricRecall    = "recall"
+)
+
+// RunTune : ハイパーパラメーター探索メイン関数
+//
+// 学習用データ(input.txt)で学習し、検証用データ(valid.txt)で評価することを繰り返す
+// 全試行の結果は[tune] trials_fileにJSON Linesで、最も良かったsupervised_argsは[tune] args_fileにTOMLで出力する
+func RunTune(ctx context.Context, config *Config, logger *zap.Logger) error {
+	if _, err := os.Stat(config.GetSupervisedSourcePath()); os.IsNotExist(err) {
+		if err := createSupervisedInput(ctx, config, logger); err != nil {
+			return err
+		}
+	}
+	if fi, err := os.Stat(config.GetValidationSourcePath()); err != nil || fi.Size() == 0 {
+		return errors.New("validation data is empty. set [supervised] validation_ratio and run supervised")
+	}
+	if err := os.MkdirAll(config.GetTuneDirPath(), 0700); err != nil {
+		return errors.WithStack(err)
+	}
+
+	f, err := os.Create(config.GetTuneTrialsPath())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	defer bw.Flush()
+	record := func(trial *TuneTrial) {
+		logger.Info("trial",
+			zap.Int("id", trial.ID),
+			zap.Strings("args", trial.Args),
+			zap.Float64("score", trial.Score),
+			zap.String("err", trial.Error))
+		if b, err := json.Marshal(trial); err == nil {
+			bw.Write(b)
+			bw.WriteString("\n")
+			bw.Flush()
+		}
+	}
+
+	method := config.Tune.Method
+	if method == TuneMethodAutotune && !supportsAutotune(ctx, config.Fasttext.Command) {
+		logger.Warn("fasttext does not support -autotune-validation. use random search instead")
+		method = TuneMethodRandom
+	}
+
+	var best *TuneTrial
+	if method == TuneMethodAutotune {
+		best, err = autotune(ctx, config)
+		if best != nil {
+			record(best)
+		}
+	} else {
+		t := newTuner(config.Tune, method, config.Fasttext.SupervisedArgs, func(ctx context.Context, id int, args []string) (*EvaluationScore, error) {
+			return trainAndEvaluate(ctx, config, args, path.Join(config.GetTuneDirPath(), "trial-"+strconv.Itoa(id)))
+		})
+		t.record = record
+		best, err = t.search(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	if best == nil || best.Error != "" {
+		return errors.New("all trials failed")
+	}
+
+	out, err := os.Create(config.GetTuneArgsPath())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer out.Close()
+	comment := fmt.Sprintf("tune: method=%s metric=%s score=%f trial=%d", method, config.Tune.Metric, best.Score, best.ID)
+	if method == TuneMethodAutotune {
+		comment += "\n" + autotuneLRNote
+	}
+	if err := writeArgsTOML(io.MultiWriter(out, os.Stdout), comment, best.Args); err != nil {
+		return err
+	}
+	return nil
+}
+
+// TuneParams : 1回の試行で変えるパラメーター
+// 0 or 空文字の場合はsupervised_argsのまま変えない
+type TuneParams struct {
+	Epoch      int     `json:"epoch,omitempty"`
+	LR         float64 `json:"lr,omitempty"`
+	Dim        int     `json:"dim,omitempty"`
+	WordNgrams int     `json:"word_ngrams,omitempty"`
+	Loss       string  `json:"loss,omitempty"`
+}
+
+// args : fasttext supervisedの引数の形式に変換する
+func (p TuneParams) args() []string {
+	res := make([]string, 0, 10)
+	if p.Epoch > 0 {
+		res = append(res, "-epoch", strconv.Itoa(p.Epoch))
+	}
+	if p.LR > 0 {
+		res = append(res, "-lr", strconv.FormatFloat(p.LR, 'g', -1, 64))
+	}
+	if p.Dim > 0 {
+		res = append(res, "-dim", strconv.Itoa(p.Dim))
+	}
+	if p.WordNgrams > 0 {
+		res = append(res, "-wordNgrams", strconv.Itoa(p.WordNgrams))
+	}
+	if p.Loss != "" {
+		res = append(res, "-loss", p.Loss)
+	}
+	return res
+}
+
+// TuneTrial : 1回の試行の結果
+type TuneTrial struct {
+	ID      int              `json:"id"`
+	Params  TuneParams       `json:"params"`
+	Args    []string         `json:"args"`
+	Score   float64          `json:"score"`
+	Overall *EvaluationScore `json:"overall,omitempty"`
+	Seconds float64          `json:"seconds"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// trialFunc : supervisedの引数で学習して、検証用データで評価する
+type trialFunc func(ctx context.Context, id int, args []string) (*EvaluationScore, error)
+
+// tuner : ハイパーパラメーター探索
+type tuner struct {
+	config   *TuneConfig
+	method   string
+	baseArgs []string
+	run      trialFunc
+	record   func(trial *TuneTrial)
+	trials   []*TuneTrial
+}
+
+func newTuner(config *TuneConfig, method string, baseArgs []string, run trialFunc) *tuner {
+	return &tuner{config: config, method: method, baseArgs: baseArgs, run: run, record: func(*TuneTrial) {}}
+}
+
+// search : 探索して、最もスコアの高い試行を返す
+func (t *tuner) search(ctx context.Context) (*TuneTrial, error) {
+	rnd := rand.New(rand.NewSource(t.config.Seed))
+	switch t.method {
+	case TuneMethodGrid:
+		for _, p := range gridParams(t.config) {
+			if _, err := t.trial(ctx, p); err != nil {
+				return nil, err
+			}
+		}
+	case TuneMethodRandom:
+		for _, p := range sampleParams(rnd, gridParams(t.config), t.config.Trials) {
+			if _, err := t.trial(ctx, p); err != nil {
+				return nil, err
+			}
+		}
+	case TuneMethodHalving:
+		if err := t.halving(ctx, rnd); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("bad tune method: %q", t.method)
+	}
+	return t.best(), nil
+}
+
+// halving : [tune] epochの少ない順に全候補を試して、上位1/halving_etaだけを次のepochに残す
+func (t *tuner) halving(ctx context.Context, rnd *rand.Rand) error {
+	epochs := append([]int{}, t.config.Epoch...)
+	if len(epochs) == 0 {
+		return errors.New("halving needs [tune] epoch")
+	}
+	sort.Ints(epochs)
+	eta := max(2, t.config.HalvingEta)
+
+	c := *t.config
+	c.Epoch = nil
+	candidates := sampleParams(rnd, gridParams(&c), t.config.Trials)
+	for i, epoch := range epochs {
+		results := make([]*TuneTrial, 0, len(candidates))
+		for _, p := range candidates {
+			p.Epoch = epoch
+			trial, err := t.trial(ctx, p)
+			if err != nil {
+				return err
+			}
+			results = append(results, trial)
+		}
+		if i == len(epochs)-1 || len(candidates) <= 1 {
+			return nil
+		}
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Score > results[j].Score
+		})
+		candidates = candidates[:0]
+		for _, trial := range results[:(len(results)+eta-1)/eta] {
+			candidates = append(candidates, trial.Params)
+		}
+	}
+	return nil
+}
+
+// trial : 1回試行する
+// 学習や評価に失敗した場合はTuneTrial.Errorに記録して、探索を継続する
+func (t *tuner) trial(ctx context.Context, p TuneParams) (*TuneTrial, error) {
+	trial := &TuneTrial{ID: len(t.trials) + 1, Params: p, Args: overrideArgs(t.baseArgs, p.args()), Score: -1}
+	start := time.Now()
+	score, err := t.run(ctx, trial.ID, trial.Args)
+	trial.Seconds = time.Since(start).Seconds()
+	if ctx.Err() != nil {
+		return nil, errors.WithStack(ctx.Err())
+	}
+	if err != nil {
+		trial.Error = err.Error()
+	} else {
+		trial.Overall = score
+		trial.Score = metricOf(t.config.Metric, score)
+	}
+	t.trials = append(t.trials, trial)
+	t.record(trial)
+	return trial, nil
+}
+
+func (t *tuner) best() *TuneTrial {
+	var best *TuneTrial
+	for _, trial := range t.trials {
+		if trial.Error == "" && (best == nil || trial.Score > best.Score) {
+			best = trial
+		}
+	}
+	return best
+}
+
+func metricOf(metric string, s *EvaluationScore) float64 {
+	switch metric {
+	case TuneMetricPrecision:
+		return s.Precision
+	case TuneMetricRecall:
+		return s.Recall
+	}
+	return s.F1
+}
+
+// gridParams : 候補の全ての組み合わせ
+func gridParams(c *TuneConfig) []TuneParams {
+	res := []TuneParams{{}}
+	expand := func(n int, set func(p *TuneParams, i int)) {
+		if n == 0 {
+			return
+		}
+		next := make([]TuneParams, 0, len(res)*n)
+		for _, p := range res {
+			for i := 0; i < n; i++ {
+				q := p
+				set(&q, i)
+				next = append(next, q)
+			}
+		}
+		res = next
+	}
+	expand(len(c.Epoch), func(p *TuneParams, i int) { p.Epoch = c.Epoch[i] })
+	expand(len(c.LR), func(p *TuneParams, i int) { p.LR = c.LR[i] })
+	expand(len(c.Dim), func(p *TuneParams, i int) { p.Dim = c.Dim[i] })
+	expand(len(c.WordNgrams), func(p *TuneParams, i int) { p.WordNgrams = c.WordNgrams[i] })
+	expand(len(c.Loss), func(p *TuneParams, i int) { p.Loss = c.Loss[i] })
+	return res
+}
+
+// sampleParams : 重複しないようにn個をランダムに選ぶ
+func sampleParams(rnd *rand.Rand, params []TuneParams, n int) []TuneParams {
+	res := append([]TuneParams{}, params...)
+	rnd.Shuffle(len(res), func(i, j int) {
+		res[i], res[j] = res[j], res[i]
+	})
+	if n > 0 && n < len(res) {
+		res = res[:n]
+	}
+	return res
+}
+
+// overrideArgs : argsのオプションの値を上書きする (ない場合は末尾に追加する)
+// Example:
+//   overrideArgs([]string{"supervised", "-dim", "200"}, []string{"-dim", "100", "-lr", "0.5"}) // supervised -dim 100 -lr 0.5
+func overrideArgs(args []string, overrides []string) []string {
+	res := append([]string{}, args...)
+	for i := 0; i+1 < len(overrides); i += 2 {
+		found := false
+		for j := 0; j+1 < len(res); j++ {
+			if res[j] == overrides[i] {
+				res[j+1] = overrides[i+1]
+				found = true
+				break
+			}
+		}
+		if !found {
+			res = append(res, overrides[i], overrides[i+1])
+		}
+	}
+	return res
+}
+
+// removeArgs : argsから値付きのオプションを取り除く
+func removeArgs(args []string, names ...string) []string {
+	res := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		if containsString(names, args[i]) && i+1 < len(args) {
+			i++
+			continue
+		}
+		res = append(res, args[i])
+	}
+	return res
+}
+
+func containsString(ar []string, s string) bool {
+	for _, v := range ar {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// writeArgsTOML : supervised_argsをTOMLの断片として出力する
+// commentは1行ごとにTOMLのコメントにする
+func writeArgsTOML(w io.Writer, comment string, args []string) error {
+	lines := make([]string, 0, len(args)+4)
+	for _, line := range strings.Split(comment, "\n") {
+		lines = append(lines, "# "+line)
+	}
+	lines = append(lines, "[fasttext]", "supervised_args = [")
+	for i, arg := range args {
+		sep := ","
+		if i == len(args)-1 {
+			sep = ""
+		}
+		lines = append(lines, "    "+strconv.Quote(arg)+sep)
+	}
+	lines = append(lines, "]", "")
+	_, err := io.WriteString(w, strings.Join(lines, "\n"))
+	return errors.WithStack(err)
+}
+
+// trainAndEvaluate : fasttext supervisedで学習して、検証用データで評価する
+func trainAndEvaluate(ctx context.Context, config *Config, args []string, modelPath string) (*EvaluationScore, error) {
+	if err := trainModel(ctx, config, args, modelPath, config.GetSupervisedSourcePath()); err != nil {
+		return nil, err
+	}
+	// 試行ごとのモデルは大きいので、評価後に削除する
+	defer os.Remove(modelPath + ".bin")
+	defer os.Remove(modelPath + ".vec")
+	return evaluateModel(ctx, config, modelPath+".bin")
+}
+
+// trainModel : inputPathの学習データでfasttext supervisedを実行する
+func trainModel(ctx context.Context, config *Config, args []string, modelPath string, inputPath string) error {
+	return trainModelLog(ctx, config, args, modelPath, inputPath, ioutil.Discard)
+}
+
+// trainModelLog : trainModelと同じ。fasttextの出力をlogにも書き込む
+func trainModelLog(ctx context.Context, config *Config, args []string, modelPath string, inputPath string, log io.Writer) error {
+	cmd := exec.CommandContext(
+		ctx,
+		config.Fasttext.Command,
+		supervisedCommandArgs(args, modelPath, inputPath)...)
+	buf := bytes.Buffer{}
+	cmd.Stdout = io.MultiWriter(&buf, log)
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Run(); err != nil {
+		out := buf.Bytes()
+		if len(out) > 1024 {
+			out = out[len(out)-1024:]
+		}
+		return errors.Wrap(err, "fasttext supervised: "+string(out))
+	}
+	return nil
+}
+
+func evaluateModel(ctx context.Context, config *Config, modelPath string) (*EvaluationScore, error) {
+	ft, err := newLabelPredictor(ctx, config, modelPath, config.Evaluate.K)
+	if err != nil {
+		return nil, err
+	}
+	defer ft.Close()
+	e, err := evaluateFile(ctx, config, ft, config.GetValidationSourcePath(), config.Evaluate.K)
+	if err != nil {
+		return nil, err
+	}
+	return e.report(nil).Overall, nil
+}
+
+// supportsAutotune : fasttextが-autotune-validationに対応しているか
+// 引数なしのsupervisedで表示されるヘルプから判定する
+func supportsAutotune(ctx context.Context, command string) bool {
+	out, _ := exec.CommandContext(ctx, command, "supervised").CombinedOutput()
+	return strings.Contains(string(out), "-autotune-validation")
+}
+
+// autotuneArgs : fastTextのautotuneが探索するオプション
+// ※手動で指定したオプションはautotuneで探索されないので、supervised_argsから取り除く
+var autotuneArgs = []string{"-epoch", "-lr", "-dim", "-wordNgrams", "-bucket", "-minn", "-maxn", "-dsub"}
+
+// autotuneLRNote : autotuneの結果に付ける注意書き
+// fastTextはモデルファイルに-lrを保存しないので、autotuneが選んだ学習率は取得できない
+const autotuneLRNote = "※autotuneが選んだ-lrはモデルファイルに保存されないので含まれない (fasttextのデフォルトは0.1)\n" +
+	"※scoreはautotuneのモデルの値。同じモデルにするには、tmp_dir/tune/autotune.logの最も良かった試行の-lrを追加する"
+
+// autotuneMetric : [tune] metricを-autotune-metricの値にする
+// fastTextには適合率/再現率だけの指標はないので、もう一方の下限を0%にして最大化する
+func autotuneMetric(metric string) string {
+	switch metric {
+	case TuneMetricPrecision:
+		return "precisionAtRecall:0"
+	case TuneMetricRecall:
+		return "recallAtPrecision:0"
+	}
+	return "f1"
+}
+
+// autotune : fastTextの-autotune-validationで探索する
+func autotune(ctx context.Context, config *Config) (*TuneTrial, error) {
+	modelPath := path.Join(config.GetTuneDirPath(), "autotune")
+	// -verbose 3で、試行ごとのパラメーター(-lrを含む)をログに出力させる
+	args := append(removeArgs(removeArgs(config.Fasttext.SupervisedArgs, autotuneArgs...), "-verbose"),
+		"-autotune-validation", config.GetValidationSourcePath(),
+		"-autotune-duration", strconv.Itoa(config.Tune.AutotuneDuration),
+		"-autotune-predictions", strconv.Itoa(config.Evaluate.K),
+		"-autotune-metric", autotuneMetric(config.Tune.Metric),
+		"-verbose", "3")
+	trial := &TuneTrial{ID: 1, Args: args, Score: -1}
+	log, err := os.Create(modelPath + ".log")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer log.Close()
+	start := time.Now()
+	if err := trainModelLog(ctx, config, args, modelPath, config.GetSupervisedSourcePath(), log); err != nil {
+		return nil, err
+	}
+	trial.Seconds = time.Since(start).Seconds()
+
+	// 探索結果のパラメーターはモデルファイルから読み取る (-lrは保存されないので含まれない。autotuneLRNoteを参照)
+	m, err := fasttext.LoadModel(modelPath + ".bin")
+	if err != nil {
+		return nil, err
+	}
+	modelArgs := m.SupervisedArgs()
+	overrides := make([]string, 0, len(autotuneArgs)*2)
+	for i := 0; i+1 < len(modelArgs); i += 2 {
+		if containsString(autotuneArgs, modelArgs[i]) {
+			overrides = append(overrides, modelArgs[i], modelArgs[i+1])
+		}
+	}
+	trial.Args = overrideArgs(removeArgs(config.Fasttext.SupervisedArgs, autotuneArgs...), overrides)
+
+	score, err := evaluateModel(ctx, config, modelPath+".bin")
+	if err != nil {
+		return nil, err
+	}
+	trial.Overall = score
+	trial.Score = metricOf(config.Tune.Metric, score)
+	return trial, nil
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func Example_tuner() {
+	config := &TuneConfig{Metric: TuneMetricF1, Trials: 4, Seed: 1, HalvingEta: 2, Epoch: []int{5, 8, 20}, Dim: []int{50, 100}, LR: []float64{0.1, 0.5}}
+	base := []string{"supervised", "-input", "{DATA_PATH}", "-dim", "200", "-thread", "4"}
+	// epoch, dimが大きく、lrが0.5に近いほど良くなる
+	run := func(ctx context.Context, id int, args []string) (*EvaluationScore, error) {
+		get := func(name string) float64 {
+			for i := range args {
+				if args[i] == name {
+					v, _ := strconv.ParseFloat(args[i+1], 64)
+					return v
+				}
+			}
+			return 0
+		}
+		if get("-dim") == 50 && get("-lr") == 0.1 {
+			return nil, fmt.Errorf("failed")
+		}
+		f1 := get("-epoch")/100 + get("-dim")/1000 - (0.5-get("-lr"))/10
+		return &EvaluationScore{F1: f1}, nil
+	}
+	for _, method := range []string{TuneMethodGrid, TuneMethodRandom, TuneMethodHalving} {
+		t := newTuner(config, method, base, run)
+		t.record = func(trial *TuneTrial) {
+			if trial.Error != "" {
+				fmt.Printf("  %d %s error=%s\n", trial.ID, strings.Join(trial.Args[3:], " "), trial.Error)
+				return
+			}
+			fmt.Printf("  %d %s score=%.3f\n", trial.ID, strings.Join(trial.Args[3:], " "), trial.Score)
+		}
+		fmt.Println(method)
+		best, err := t.search(context.Background())
+		fmt.Println(" best:", best.ID, err)
+	}
+
+	fmt.Println(overrideArgs([]string{"supervised", "-dim", "200"}, []string{"-dim", "100", "-lr", "0.5"}))
+	fmt.Println(removeArgs([]string{"supervised", "-dim", "200", "-epoch", "5", "-thread", "4"}, "-dim", "-epoch"))
+	writeArgsTOML(os.Stdout, "tune: test", []string{"supervised", "-dim", "100"})
+	writeArgsTOML(os.Stdout, "tune: autotune\n"+autotuneLRNote, []string{"supervised", "-dim", "100"})
+	fmt.Println(autotuneMetric(TuneMetricF1), autotuneMetric(TuneMetricPrecision), autotuneMetric(TuneMetricRecall))
+	// Output:
+	// grid
+	//   1 -dim 50 -thread 4 -epoch 5 -lr 0.1 error=failed
+	//   2 -dim 100 -thread 4 -epoch 5 -lr 0.1 score=0.110
+	//   3 -dim 50 -thread 4 -epoch 5 -lr 0.5 score=0.100
+	//   4 -dim 100 -thread 4 -epoch 5 -lr 0.5 score=0.150
+	//   5 -dim 50 -thread 4 -epoch 8 -lr 0.1 error=failed
+	//   6 -dim 100 -thread 4 -epoch 8 -lr 0.1 score=0.140
+	//   7 -dim 50 -thread 4 -epoch 8 -lr 0.5 score=0.130
+	//   8 -dim 100 -thread 4 -epoch 8 -lr 0.5 score=0.180
+	//   9 -dim 50 -thread 4 -epoch 20 -lr 0.1 error=failed
+	//   10 -dim 100 -thread 4 -epoch 20 -lr 0.1 score=0.260
+	//   11 -dim 50 -thread 4 -epoch 20 -lr 0.5 score=0.250
+	//   12 -dim 100 -thread 4 -epoch 20 -lr 0.5 score=0.300
+	//  best: 12 <nil>
+	// random
+	//   1 -dim 50 -thread 4 -epoch 5 -lr 0.5 score=0.100
+	//   2 -dim 100 -thread 4 -epoch 5 -lr 0.1 score=0.110
+	//   3 -dim 100 -thread 4 -epoch 20 -lr 0.5 score=0.300
+	//   4 -dim 100 -thread 4 -epoch 20 -lr 0.1 score=0.260
+	//  best: 3 <nil>
+	// halving
+	//   1 -dim 50 -thread 4 -epoch 5 -lr 0.1 error=failed
+	//   2 -dim 100 -thread 4 -epoch 5 -lr 0.1 score=0.110
+	//   3 -dim 100 -thread 4 -epoch 5 -lr 0.5 score=0.150
+	//   4 -dim 50 -thread 4 -epoch 5 -lr 0.5 score=0.100
+	//   5 -dim 100 -thread 4 -epoch 8 -lr 0.5 score=0.180
+	//   6 -dim 100 -thread 4 -epoch 8 -lr 0.1 score=0.140
+	//   7 -dim 100 -thread 4 -epoch 20 -lr 0.5 score=0.300
+	//  best: 7 <nil>
+	// [supervised -dim 100 -lr 0.5]
+	// [supervised -thread 4]
+	// # tune: test
+	// [fasttext]
+	// supervised_args = [
+	//     "supervised",
+	//     "-dim",
+	//     "100"
+	// ]
+	// # tune: autotune
+	// # ※autotuneが選んだ-lrはモデルファイルに保存されないので含まれない (fasttextのデフォルトは0.1)
+	// # ※scoreはautotuneのモデルの値。同じモデルにするには、tmp_dir/tune/autotune.logの最も良かった試行の-lrを追加する
+	// [fasttext]
+	// supervised_args = [
+	//     "supervised",
+	//     "-dim",
+	//     "100"
+	// ]
+	// f1 precisionAtRecall:0 recallAtPrecision:0
+}
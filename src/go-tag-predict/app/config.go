@@ -1,8 +1,15 @@
 package app
 
 import (
+	"bytes"
 	"go-tag-predict/fileutil"
+	"go-tag-predict/importer"
+	"go-tag-predict/lambda"
+	"go-tag-predict/webservice/pinboard"
+	"os"
 	"path"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/pkg/errors"
@@ -10,53 +17,400 @@ import (
 
 // Config : 設定ファイル
 type Config struct {
-	CacheDirPath string `toml:"cache_dir"`
-	TmpDirPath   string `toml:"tmp_dir"`
-	Tokenizer    string `toml:"tokenizer"`
+	CacheDirPath string             `toml:"cache_dir"`
+	TmpDirPath   string             `toml:"tmp_dir"`
+	Tokenizer    *TokenizerConfig   `toml:"tokenizer"`
+	TokenFilter  *TokenFilterConfig `toml:"token_filter"`
+	Language     *LanguageConfig
 	Supervised   *SupervisedConfig
+	Tags         *TagsConfig
 	Predict      *PredictConfig
+	Serve        *ServeConfig
+	Evaluate     *EvaluateConfig
+	Tune         *TuneConfig
+	Retag        *RetagConfig
+	Pinboard     *PinboardConfig
 	Fasttext     *FasttextConfig
 	Mecab        *MecabConfig
 	Jumanpp      *JumanppConfig
+	Kagome       *KagomeConfig
+	Unicode      *UnicodeConfig
+
+	// tokenizers : tokenizerの名前 => LoadConfigでtokenizerと[language]の設定から生成したTokenizer
+	tokenizers map[string]Tokenizer
+	// tokenFilter : LoadConfigで[token_filter]から生成する (nilの場合はトークンを変換しない)
+	tokenFilter *TokenFilterChain
 }
 
 // PredictConfig : 学習処理の設定
 type SupervisedConfig struct {
-	LearningSourceFilePath string `toml:"learning_source_file"`
-	ParallelsCount         int    `toml:"parallels_count"`
-	WriterBufferSize       int    `toml:"writer_buffer_size"`
-	WriterQueueCount       int    `toml:"writer_queue_count"`
+	LearningSource         string  `toml:"learning_source"`
+	LearningSourceFilePath string  `toml:"learning_source_file"`
+	LearningSourceFormat   string  `toml:"learning_source_format"`
+	IncludePrivate         bool    `toml:"include_private"`
+	UseExtended            bool    `toml:"use_extended"`
+	TimeFrom               string  `toml:"time_from"`
+	TimeTo                 string  `toml:"time_to"`
+	ParallelsCount         int     `toml:"parallels_count"`
+	WriterBufferSize       int     `toml:"writer_buffer_size"`
+	WriterQueueCount       int     `toml:"writer_queue_count"`
+	LabelMode              string  `toml:"label_mode"`
+	SplitSeed              int64   `toml:"split_seed"`
+	ValidationRatio        float64 `toml:"validation_ratio"`
+	TestRatio              float64 `toml:"test_ratio"`
+	UnkMinCount            int     `toml:"unk_min_count"`
+}
+
+// GetTimeRange : 学習に使うブックマークの日時の範囲 (指定しない場合はゼロ値)
+// time_from, time_toはRFC3339か"2006-01-02"の形式。日付だけの場合、time_toはその日を含む
+func (c *SupervisedConfig) GetTimeRange() (time.Time, time.Time, error) {
+	from, err := parseConfigTime(c.TimeFrom)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.Wrap(err, "bad time_from")
+	}
+	to, err := parseConfigTime(c.TimeTo)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.Wrap(err, "bad time_to")
+	}
+	if len(c.TimeTo) == len(configDateFormat) {
+		to = to.AddDate(0, 0, 1)
+	}
+	return from, to, nil
+}
+
+// configDateFormat : 設定ファイルの日付の形式 (ローカル時刻)
+const configDateFormat = "2006-01-02"
+
+func parseConfigTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if len(s) == len(configDateFormat) {
+		t, err := time.ParseInLocation(configDateFormat, s, time.Local)
+		return t, errors.WithStack(err)
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t, errors.WithStack(err)
+}
+
+// TokenizerConfig : 分かち書きの設定
+//
+// tokenizer = "mecab" のように名前だけを指定するか、[tokenizer]のテーブルで指定する
+// Example:
+//   [tokenizer]
+//   name = "external"
+//   [tokenizer.external]
+//   command = "sudachipy"
+type TokenizerConfig struct {
+	Name     string                   `toml:"name"`
+	External *ExternalTokenizerConfig `toml:"external"`
+}
+
+// UnmarshalTOML : 文字列(名前)とテーブルのどちらでも読み込めるようにする
+func (c *TokenizerConfig) UnmarshalTOML(data interface{}) error {
+	switch v := data.(type) {
+	case string:
+		c.Name = v
+		return nil
+	case map[string]interface{}:
+		// テーブルはエンコードし直して、UnmarshalTOMLを持たない型で読み込む
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+			return errors.WithStack(err)
+		}
+		type tokenizerConfig TokenizerConfig
+		var t tokenizerConfig
+		if _, err := toml.Decode(buf.String(), &t); err != nil {
+			return errors.WithStack(err)
+		}
+		*c = TokenizerConfig(t)
+		return nil
+	}
+	return errors.Errorf("bad tokenizer: %v (name or table)", data)
+}
+
+// ExternalTokenizerConfig : 外部コマンドで分かち書きする設定 ([tokenizer.external])
+//
+// Sudachi, KyTea, spaCyのラッパーなどを、コードを変更せずに使う
+type ExternalTokenizerConfig struct {
+	Command string   `toml:"command"`
+	Args    []string `toml:"args"`
+	// Input : 文書の区切り方 (line or nul)
+	Input string `toml:"input"`
+	// Output : 出力の形式 (wakati, token or json)
+	Output string `toml:"output"`
+	// Mode : プロセスを常駐させるか、文書ごとに実行するか (resident or oneshot)
+	Mode string `toml:"mode"`
+	// BaseForm : 表層形の代わりに原形(lemma)を使う
+	BaseForm bool `toml:"base_form"`
+	// POS : 残す品詞 (空の場合は全て残す)。前方一致で"名詞"は"名詞,普通名詞"にも当てはまる
+	POS []string `toml:"pos"`
+	// ProcessCount : residentで常駐させるプロセス数 (0の場合はCPU数)
+	ProcessCount int `toml:"process_count"`
+	// TimeoutSeconds : 1文書の分かち書きのタイムアウト秒数 (0の場合は無制限)
+	TimeoutSeconds int `toml:"timeout_seconds"`
+}
+
+// 外部コマンドへの文書の区切り方
+const (
+	// ExternalInputLine : 改行を空白に置き換えて、1文書を1行で送る
+	ExternalInputLine = "line"
+	// ExternalInputNUL : 文書の後にNUL文字を送る
+	ExternalInputNUL = "nul"
+)
+
+// 外部コマンドの出力の形式 (どの形式も、1文書分の出力の後に次の文書の出力が続く)
+const (
+	// ExternalOutputWakati : 1文書を1行で、単語を空白で区切る
+	ExternalOutputWakati = "wakati"
+	// ExternalOutputToken : 1行が「表層形<TAB>品詞<TAB>原形」の1単語で、空行かEOSで文書が終わる (品詞と原形は省略できる)
+	ExternalOutputToken = "token"
+	// ExternalOutputJSON : 1文書を1行で、[{"surface": "...", "lemma": "...", "pos": "..."}, ...]
+	ExternalOutputJSON = "json"
+)
+
+// 外部コマンドの実行方法
+const (
+	// ExternalModeResident : process_count個のプロセスを常駐させて使い回す
+	ExternalModeResident = "resident"
+	// ExternalModeOneshot : 1文書ごとにコマンドを実行する (標準入力を閉じるまで出力しないコマンド向け)
+	ExternalModeOneshot = "oneshot"
+)
+
+// LanguageConfig : 言語の判定と、言語ごとの分かち書きの設定
+type LanguageConfig struct {
+	// Enabled : 文書の言語を判定して、言語ごとのtokenizerで分かち書きする
+	Enabled bool `toml:"enabled" json:"enabled"`
+	// Default : 判定できなかった文書の言語 (空の場合は言語なしとしてtokenizerで分かち書きする)
+	Default string `toml:"default" json:"default"`
+	// Tokenizers : 言語 => tokenizerの名前 (指定しない言語はtokenizerを使う)
+	Tokenizers map[string]string `toml:"tokenizers" json:"tokenizers"`
+	// Feature : 判定した言語を、<lang:ja>のようなトークンとして加える
+	Feature bool `toml:"feature" json:"feature"`
+}
+
+// TokenFilterConfig : 分かち書きしたトークンの変換/除外の設定
+type TokenFilterConfig struct {
+	NFKC            bool `toml:"nfkc" json:"nfkc"`
+	Lowercase       bool `toml:"lowercase" json:"lowercase"`
+	RemovePunct     bool `toml:"remove_punct" json:"remove_punct"`
+	CollapseNumbers bool `toml:"collapse_numbers" json:"collapse_numbers"`
+	CollapseURLs    bool `toml:"collapse_urls" json:"collapse_urls"`
+	// Stopwords : 言語 => ストップワードのファイルのパス
+	Stopwords map[string]string `toml:"stopwords" json:"-"`
+	MinLength int               `toml:"min_length" json:"min_length"`
+	MaxLength int               `toml:"max_length" json:"max_length"`
+}
+
+// TagsConfig : 学習前のタグの正規化/除外の設定
+type TagsConfig struct {
+	CaseFold bool `toml:"case_fold"`
+	NFKC     bool `toml:"nfkc"`
+	// Aliases : 別名 => 正式なタグ
+	Aliases map[string]string `toml:"aliases"`
+	// Deny : 除外するタグのglobパターン (path.Matchの形式)
+	Deny        []string `toml:"deny"`
+	MinTagCount int      `toml:"min_tag_count"`
+	MaxTags     int      `toml:"max_tags"`
 }
 
 // PredictConfig : 分類処理の設定
 type PredictConfig struct {
-	FeedURLs       []string `toml:"feed_urls"`
-	ParallelsCount int      `toml:"parallels_count"`
-	MinProbability float64  `toml:"min_probability"`
+	FeedURLs              []string         `toml:"feed_urls"`
+	ParallelsCount        int              `toml:"parallels_count"`
+	MinProbability        float64          `toml:"min_probability"`
+	TopK                  int              `toml:"top_k"`
+	CumulativeProbability float64          `toml:"cumulative_probability"`
+	OOVPolicy             string           `toml:"oov_policy"`
+	OutputFormat          string           `toml:"output_format"`
+	OutputFilePath        string           `toml:"output_file"`
+	Writeback             *WritebackConfig `toml:"writeback"`
+}
+
+// WritebackConfig : 分類結果をPinboardに保存する設定 ([predict.writeback])
+type WritebackConfig struct {
+	Enabled   bool   `toml:"enabled"`
+	MarkerTag string `toml:"marker_tag"`
+	ToRead    bool   `toml:"toread"`
+	Shared    bool   `toml:"shared"`
+	Replace   bool   `toml:"replace"`
+	DryRun    bool   `toml:"dry_run"`
+}
+
+// GetTopK : 1ページあたりに予測するタグの最大数
+func (c *PredictConfig) GetTopK() int {
+	if c.TopK <= 0 {
+		return 1
+	}
+	return c.TopK
+}
+
+// ServeConfig : 分類APIサーバーの設定
+type ServeConfig struct {
+	Listen         string `toml:"listen"`
+	ParallelsCount int    `toml:"parallels_count"`
+	MaxBatchSize   int    `toml:"max_batch_size"`
+	// MaxBodyBytes : リクエストボディの最大サイズ (0の場合は1MB)
+	MaxBodyBytes int64 `toml:"max_body_bytes"`
+	// ReadTimeoutSeconds, WriteTimeoutSeconds, IdleTimeoutSeconds : http.Serverのタイムアウト秒数 (0の場合は無制限)
+	ReadTimeoutSeconds  int `toml:"read_timeout_seconds"`
+	WriteTimeoutSeconds int `toml:"write_timeout_seconds"`
+	IdleTimeoutSeconds  int `toml:"idle_timeout_seconds"`
+}
+
+// defaultMaxBodyBytes : max_body_bytesを指定しない場合のリクエストボディの最大サイズ
+const defaultMaxBodyBytes = 1024 * 1024
+
+// GetMaxBodyBytes : リクエストボディの最大サイズ
+func (c *ServeConfig) GetMaxBodyBytes() int64 {
+	if c.MaxBodyBytes <= 0 {
+		return defaultMaxBodyBytes
+	}
+	return c.MaxBodyBytes
+}
+
+// EvaluateConfig : 評価処理の設定
+type EvaluateConfig struct {
+	K              int    `toml:"k"`
+	DataSet        string `toml:"data"`
+	OutputFilePath string `toml:"output_file"`
+}
+
+// 評価に使うデータ
+const (
+	EvaluateDataTest       = "test"
+	EvaluateDataValidation = "valid"
+)
+
+// TuneConfig : ハイパーパラメーター探索の設定
+//
+// epoch, lr, dim, word_ngrams, lossは候補の値を並べる (空の場合はsupervised_argsのまま探索しない)
+type TuneConfig struct {
+	Method           string    `toml:"method"`
+	Metric           string    `toml:"metric"`
+	Trials           int       `toml:"trials"`
+	Seed             int64     `toml:"seed"`
+	HalvingEta       int       `toml:"halving_eta"`
+	AutotuneDuration int       `toml:"autotune_duration"`
+	Epoch            []int     `toml:"epoch"`
+	LR               []float64 `toml:"lr"`
+	Dim              []int     `toml:"dim"`
+	WordNgrams       []int     `toml:"word_ngrams"`
+	Loss             []string  `toml:"loss"`
+	TrialsFilePath   string    `toml:"trials_file"`
+	ArgsFilePath     string    `toml:"args_file"`
+}
+
+// RetagConfig : 既存のブックマークにタグを付け直す処理の設定
+type RetagConfig struct {
+	// MinTags : タグがこの数未満のブックマークに、予測したタグを追加する
+	MinTags int `toml:"min_tags"`
+	// Folds : 学習データに含まれるブックマークは、この数に分割して交差検証で予測する
+	Folds          int    `toml:"folds"`
+	Apply          string `toml:"apply"`
+	OutputFilePath string `toml:"output_file"`
+	DryRun         bool   `toml:"dry_run"`
+}
+
+// タグの反映先
+const (
+	// RetagApplyFile : タグを追加したXMLファイルを書き出す
+	RetagApplyFile = "file"
+	// RetagApplyAPI : Pinboard APIでブックマークを更新する
+	RetagApplyAPI = "api"
+)
+
+// PinboardConfig : Pinboard APIの設定
+type PinboardConfig struct {
+	Token          string `toml:"token"`
+	APIURL         string `toml:"api_url"`
+	MirrorFilePath string `toml:"mirror_file"`
+}
+
+// GetToken : APIトークン
+// 設定ファイルで指定しない場合は、環境変数PINBOARD_TOKENを使う
+func (c *PinboardConfig) GetToken() string {
+	if c.Token != "" {
+		return c.Token
+	}
+	return os.Getenv("PINBOARD_TOKEN")
 }
 
 // FasttextConfig : fastTextの設定
 type FasttextConfig struct {
-	Command        string   `toml:"command"`
+	Engine         string   `toml:"engine"`
+	Command string   `toml:"command"`
 	SupervisedArgs []string `toml:"supervised_args"`
 	PredictArgs    []string `toml:"predict_args"`
+	ProcessCount   int      `toml:"process_count"`
+	TimeoutSeconds int      `toml:"timeout_seconds"`
 }
 
 // MecabConfig : Mecabの設定
 type MecabConfig struct {
 	DictDirPath string `toml:"dict_dir"`
+	// BaseForm : 表層形の代わりに原形を使う (すきとおっ => すきとおる)
+	BaseForm bool `toml:"base_form"`
+	// BaseFormIndex : 素性の中の原形の位置 (IPA辞書は6, UniDicは7など)
+	BaseFormIndex int `toml:"base_form_index"`
+	// POS : 残す品詞 (空の場合は全て残す)。"名詞"や"名詞,固有名詞"のように品詞細分類まで指定できる
+	POS []string `toml:"pos"`
+	// PoolSize : 辞書を共有して使い回すtaggerの数 (0の場合はCPU数)
+	PoolSize int `toml:"pool_size"`
 }
 
 // JumanppConfig : juman++の設定
 type JumanppConfig struct {
-	Command        string   `toml:"command"`
-	Args           []string `toml:"args"`
-	TokenSeparator string   `toml:"token_separator"`
+	Command string   `toml:"command"`
+	Args    []string `toml:"args"`
+	// TokenSeparator : 出力の1行を表層形, 読み, 原形, ...に分ける区切り文字 (空の場合は" ")
+	TokenSeparator string `toml:"token_separator"`
+	// BaseForm : 表層形の代わりに原形を使う
+	BaseForm bool `toml:"base_form"`
+	// POS : 残す品詞 (空の場合は全て残す)。"名詞"や"名詞,普通名詞"のように品詞細分類まで指定できる
+	POS []string `toml:"pos"`
+	// ProcessCount : 常駐させるプロセス数 (0の場合はCPU数)
+	ProcessCount int `toml:"process_count"`
+	// TimeoutSeconds : 1文書の分かち書きのタイムアウト秒数 (超えた場合はプロセスを起動し直す。0の場合は無制限)
+	TimeoutSeconds int `toml:"timeout_seconds"`
+}
+
+// KagomeConfig : kagomeの設定
+type KagomeConfig struct {
+	// BaseForm : 表層形の代わりに原形を使う
+	BaseForm bool `toml:"base_form"`
+	// POS : 残す品詞 (空の場合は全て残す)。"名詞"や"名詞,固有名詞"のように品詞細分類まで指定できる
+	POS []string `toml:"pos"`
+}
+
+// UnicodeConfig : Unicodeの文字種で単語に分割する設定 (英語など、空白で単語を区切る言語向け)
+type UnicodeConfig struct {
+	// Stem : 小文字にして、Porterのステミングで語幹にする (connections => connect)
+	Stem bool `toml:"stem"`
 }
 
 // NewConfig : Configのコンストラクタ
+// 設定ファイルにない項目やセクションは、ここで指定した値になる
 func NewConfig() *Config {
-	return &Config{}
+	return &Config{
+		Tokenizer:   &TokenizerConfig{},
+		TokenFilter: &TokenFilterConfig{},
+		Language:    &LanguageConfig{},
+		Supervised:  &SupervisedConfig{ParallelsCount: 30, WriterBufferSize: 524288, WriterQueueCount: 64, SplitSeed: 1},
+		Tags:        &TagsConfig{},
+		Predict:     &PredictConfig{ParallelsCount: 5},
+		Serve:       &ServeConfig{Listen: "localhost:8080", ParallelsCount: 5, MaxBatchSize: 100, ReadTimeoutSeconds: 30, WriteTimeoutSeconds: 300, IdleTimeoutSeconds: 120},
+		Evaluate:    &EvaluateConfig{K: 1, DataSet: EvaluateDataTest},
+		Retag:       &RetagConfig{MinTags: 2, Folds: 5, Apply: RetagApplyFile, DryRun: true},
+		Pinboard:    &PinboardConfig{APIURL: pinboard.DefaultBaseURL},
+		Tune:        &TuneConfig{Method: TuneMethodRandom, Metric: TuneMetricF1, Trials: 10, Seed: 1, HalvingEta: 3, AutotuneDuration: 300},
+		Fasttext: &FasttextConfig{
+			Engine:         FasttextEngineCommand,
+			Command:        "fasttext",
+			SupervisedArgs: []string{"supervised", "-input", "{DATA_PATH}", "-output", "{MODEL_PATH}"},
+			PredictArgs:    []string{"predict-prob", "{MODEL_PATH}", "-", "{TOP_K}"},
+		},
+	}
 }
 
 // LoadConfig : Configをtomlの設定ファイルからロード
@@ -69,11 +423,136 @@ func LoadConfig(configPath string) (*Config, error) {
 
 	config.Supervised.LearningSourceFilePath = fileutil.FindFilePath(config.Supervised.LearningSourceFilePath)
 	config.CacheDirPath = fileutil.FindFilePath(config.CacheDirPath)
+	if config.Pinboard.MirrorFilePath == "" {
+		config.Pinboard.MirrorFilePath = path.Join(config.CacheDirPath, "pinboard.xml")
+	} else {
+		config.Pinboard.MirrorFilePath = fileutil.FindFilePath(config.Pinboard.MirrorFilePath)
+	}
 	config.TmpDirPath = fileutil.FindFilePath(config.TmpDirPath)
+	for lang, filePath := range config.TokenFilter.Stopwords {
+		config.TokenFilter.Stopwords[lang] = fileutil.FindFilePath(filePath)
+	}
+	if config.TokenFilter.MinLength < 0 || config.TokenFilter.MaxLength < 0 {
+		return nil, errors.Errorf("bad token_filter min_length/max_length: %d, %d (0 <= value)", config.TokenFilter.MinLength, config.TokenFilter.MaxLength)
+	}
+	if config.tokenFilter, err = NewTokenFilterChain(config.TokenFilter); err != nil {
+		return nil, err
+	}
+	// 空文字で区切ると1文字ずつに分かれて、表層形や品詞を取り出せない
+	if config.Jumanpp != nil && config.Jumanpp.TokenSeparator == "" {
+		config.Jumanpp.TokenSeparator = " "
+	}
+	if config.Tokenizer.Name == "" {
+		config.Tokenizer.Name = defaultTokenizerName
+		if config.Tokenizer.External != nil {
+			config.Tokenizer.Name = "external"
+		}
+	}
 
+	switch config.Fasttext.Engine {
+	case "", FasttextEngineCommand, FasttextEngineNative:
+	default:
+		return nil, errors.Errorf("bad fasttext engine: %q (command or native)", config.Fasttext.Engine)
+	}
+	// predict-probはkを省略すると1個しか返さないので、top_kを無視しないようにする
+	if config.Fasttext.Engine != FasttextEngineNative && config.Predict.GetTopK() > 1 &&
+		len(lambda.FilterString(config.Fasttext.PredictArgs, func(s string) bool { return s == "{TOP_K}" })) == 0 {
+		return nil, errors.Errorf("[fasttext] predict_args must contain {TOP_K} to use [predict] top_k = %d", config.Predict.GetTopK())
+	}
+	switch config.Supervised.LearningSource {
+	case "":
+		config.Supervised.LearningSource = LearningSourceFile
+	case LearningSourceFile, LearningSourcePinboard:
+	default:
+		return nil, errors.Errorf("bad learning_source: %q (file or pinboard)", config.Supervised.LearningSource)
+	}
+	if !importer.IsFormat(config.Supervised.LearningSourceFormat) {
+		return nil, errors.Errorf("bad learning_source_format: %q (auto, %s)", config.Supervised.LearningSourceFormat, strings.Join(importer.Formats(), ", "))
+	}
+	if _, _, err := config.Supervised.GetTimeRange(); err != nil {
+		return nil, err
+	}
+	if _, err := NewTagNormalizer(config.Tags); err != nil {
+		return nil, err
+	}
+	if config.Tags.MinTagCount < 0 || config.Tags.MaxTags < 0 {
+		return nil, errors.Errorf("bad min_tag_count/max_tags: %d, %d (0 <= value)", config.Tags.MinTagCount, config.Tags.MaxTags)
+	}
+	switch config.Supervised.LabelMode {
+	case "":
+		config.Supervised.LabelMode = LabelModeMulti
+	case LabelModeMulti, LabelModeExpand:
+	default:
+		return nil, errors.Errorf("bad label_mode: %q (multi or expand)", config.Supervised.LabelMode)
+	}
+	if config.Supervised.ValidationRatio < 0 || config.Supervised.TestRatio < 0 || config.Supervised.ValidationRatio+config.Supervised.TestRatio >= 1 {
+		return nil, errors.Errorf("bad validation_ratio/test_ratio: %v, %v (0 <= ratio, sum < 1)", config.Supervised.ValidationRatio, config.Supervised.TestRatio)
+	}
+	switch config.Evaluate.DataSet {
+	case EvaluateDataTest, EvaluateDataValidation:
+	default:
+		return nil, errors.Errorf("bad evaluate data: %q (test or valid)", config.Evaluate.DataSet)
+	}
+	if config.Evaluate.K <= 0 {
+		return nil, errors.Errorf("bad evaluate k: %d", config.Evaluate.K)
+	}
+	switch config.Tune.Method {
+	case TuneMethodGrid, TuneMethodRandom, TuneMethodHalving, TuneMethodAutotune:
+	default:
+		return nil, errors.Errorf("bad tune method: %q (grid, random, halving or autotune)", config.Tune.Method)
+	}
+	switch config.Tune.Metric {
+	case TuneMetricF1, TuneMetricPrecision, TuneMetricRecall:
+	default:
+		return nil, errors.Errorf("bad tune metric: %q (f1, precision or recall)", config.Tune.Metric)
+	}
+	switch config.Retag.Apply {
+	case RetagApplyFile, RetagApplyAPI:
+	default:
+		return nil, errors.Errorf("bad retag apply: %q (file or api)", config.Retag.Apply)
+	}
+	if config.Retag.Folds < 2 {
+		return nil, errors.Errorf("bad retag folds: %d (2 <= folds)", config.Retag.Folds)
+	}
+	switch config.Predict.OOVPolicy {
+	case "":
+		config.Predict.OOVPolicy = OOVPolicyDrop
+	case OOVPolicyDrop, OOVPolicyUnk, OOVPolicyKeep:
+	default:
+		return nil, errors.Errorf("bad oov_policy: %q (drop, unk or keep)", config.Predict.OOVPolicy)
+	}
+	if config.Supervised.UnkMinCount < 0 {
+		return nil, errors.Errorf("bad unk_min_count: %d (0 <= unk_min_count)", config.Supervised.UnkMinCount)
+	}
+	// unkは学習時に<unk>を学習していないと意味がないので、出現回数1回の単語を<unk>にまとめる
+	if config.Predict.OOVPolicy == OOVPolicyUnk && config.Supervised.UnkMinCount == 0 {
+		config.Supervised.UnkMinCount = 2
+	}
+	switch config.Predict.OutputFormat {
+	case "", OutputFormatJSONL, OutputFormatCSV, OutputFormatTSV:
+	default:
+		return nil, errors.Errorf("bad output_format: %q (jsonl, csv or tsv)", config.Predict.OutputFormat)
+	}
+
+	// Juman++などのプロセスを起動するので、設定の確認が全て終わってから生成する
+	if err := config.openTokenizers(); err != nil {
+		return nil, err
+	}
 	return config, nil
 }
 
+// Close : LoadConfigで生成したTokenizerを解放する
+func (c *Config) Close() error {
+	var err error
+	for name, t := range c.tokenizers {
+		if e := t.Close(); e != nil && err == nil {
+			err = e
+		}
+		delete(c.tokenizers, name)
+	}
+	return err
+}
+
 // GetModelPathForSupervised : fasttext学習データの場所(supervised用の形式)
 func (c *Config) GetModelPathForSupervised() string {
 	return path.Join(c.TmpDirPath, "model")
@@ -84,12 +563,87 @@ func (c *Config) GetModelPathForPredict() string {
 	return c.GetModelPathForSupervised() + ".bin"
 }
 
-// GetTagIDPath : 単語=>数値変換表の場所
+// GetTagIDPath : タグ(ラベル)=>数値変換表の場所
 func (c *Config) GetTagIDPath() string {
 	return path.Join(c.TmpDirPath, "tagid.txt")
 }
 
+// GetVocabIDPath : 本文の単語=>数値変換表の場所
+func (c *Config) GetVocabIDPath() string {
+	return path.Join(c.TmpDirPath, "vocabid.txt")
+}
+
 // GetSupervisedSourcePath : fasttext学習の入力データの場所
 func (c *Config) GetSupervisedSourcePath() string {
 	return path.Join(c.TmpDirPath, "input.txt")
 }
+
+// GetValidationSourcePath : 検証用データの場所
+func (c *Config) GetValidationSourcePath() string {
+	return path.Join(c.TmpDirPath, "valid.txt")
+}
+
+// GetTestSourcePath : テスト用データの場所
+func (c *Config) GetTestSourcePath() string {
+	return path.Join(c.TmpDirPath, "test.txt")
+}
+
+// GetEvaluateSourcePath : 評価に使うデータの場所
+func (c *Config) GetEvaluateSourcePath() string {
+	if c.Evaluate.DataSet == EvaluateDataValidation {
+		return c.GetValidationSourcePath()
+	}
+	return c.GetTestSourcePath()
+}
+
+// GetTuneDirPath : ハイパーパラメーター探索中のモデルの場所
+func (c *Config) GetTuneDirPath() string {
+	return path.Join(c.TmpDirPath, "tune")
+}
+
+// GetTuneTrialsPath : ハイパーパラメーター探索の全試行の結果(JSON Lines)の出力先
+func (c *Config) GetTuneTrialsPath() string {
+	if c.Tune.TrialsFilePath != "" {
+		return c.Tune.TrialsFilePath
+	}
+	return path.Join(c.TmpDirPath, "tune_trials.jsonl")
+}
+
+// GetTuneArgsPath : 最も良かったsupervised_args(TOML)の出力先
+func (c *Config) GetTuneArgsPath() string {
+	if c.Tune.ArgsFilePath != "" {
+		return c.Tune.ArgsFilePath
+	}
+	return path.Join(c.TmpDirPath, "tune_args.toml")
+}
+
+// GetEvaluateReportPath : 評価結果(JSON)の出力先
+func (c *Config) GetEvaluateReportPath() string {
+	if c.Evaluate.OutputFilePath != "" {
+		return c.Evaluate.OutputFilePath
+	}
+	return path.Join(c.TmpDirPath, "evaluation.json")
+}
+
+// GetTokenFilterPath : 学習時の[token_filter]のハッシュ値の場所
+func (c *Config) GetTokenFilterPath() string {
+	return path.Join(c.TmpDirPath, "token_filter.txt")
+}
+
+// GetTagsMigrationLogPath : tagsコマンドの移行ログ(JSON Lines)の出力先
+func (c *Config) GetTagsMigrationLogPath() string {
+	return path.Join(c.TmpDirPath, "tags_migration.jsonl")
+}
+
+// GetRetagDirPath : タグの付け直しで交差検証するモデルの場所
+func (c *Config) GetRetagDirPath() string {
+	return path.Join(c.TmpDirPath, "retag")
+}
+
+// GetRetagOutputPath : タグを追加したXMLファイルの出力先
+func (c *Config) GetRetagOutputPath() string {
+	if c.Retag.OutputFilePath != "" {
+		return c.Retag.OutputFilePath
+	}
+	return path.Join(c.TmpDirPath, "retagged.xml")
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func ExampleLoadConfig() {
+	dir, _ := ioutil.TempDir("", "config")
+	defer os.RemoveAll(dir)
+	configPath := filepath.Join(dir, "go-tag-predict.toml")
+
+	// [supervised], [predict], [fasttext]などのセクションがない場合は、NewConfigの値を使う
+	ioutil.WriteFile(configPath, []byte("tokenizer = \"unicode\"\n"), 0600)
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer config.Close()
+	fmt.Println(config.Supervised.LearningSource, config.Supervised.LabelMode, config.Supervised.ParallelsCount)
+	fmt.Println(config.Predict.OOVPolicy, config.Predict.ParallelsCount, config.Predict.GetTopK())
+	fmt.Println(config.Fasttext.Engine, config.Fasttext.Command, config.Fasttext.PredictArgs)
+
+	// セクションの一部だけを指定した場合も、指定しない項目はNewConfigの値になる
+	ioutil.WriteFile(configPath, []byte("tokenizer = \"unicode\"\n[predict]\ntop_k = 3\n"), 0600)
+	config, err = LoadConfig(configPath)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer config.Close()
+	fmt.Println(config.Predict.ParallelsCount, config.Predict.GetTopK())
+
+	// [jumanpp]のtoken_separatorが空の場合は" "を使う
+	ioutil.WriteFile(configPath, []byte("tokenizer = \"unicode\"\n[jumanpp]\ncommand = \"jumanpp\"\ntoken_separator = \"\"\n"), 0600)
+	config, err = LoadConfig(configPath)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer config.Close()
+	fmt.Printf("%q\n", config.Jumanpp.TokenSeparator)
+	// Output:
+	// file multi 30
+	// drop 5 1
+	// command fasttext [predict-prob {MODEL_PATH} - {TOP_K}]
+	// 5 3
+	// " "
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"go-tag-predict/fasttext"
+	"go-tag-predict/lambda"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// fastTextの実行方法
+const (
+	// FasttextEngineCommand : 外部プログラムのfasttextを常駐させる
+	FasttextEngineCommand = "command"
+	// FasttextEngineNative : Goで.binを読み込んで分類する
+	FasttextEngineNative = "native"
+)
+
+// labelPredictor : fastTextで1文書を分類する
+type labelPredictor interface {
+	Predict(ctx context.Context, tokens string) ([]fasttext.Prediction, error)
+	Close() error
+}
+
+// newLabelPredictor : 上位k個のラベルを返すlabelPredictorを生成する
+func newLabelPredictor(ctx context.Context, config *Config, modelPath string, k int) (labelPredictor, error) {
+	switch config.Fasttext.Engine {
+	case "", FasttextEngineCommand:
+		processCount := config.Fasttext.ProcessCount
+		if processCount <= 0 {
+			processCount = max(1, config.Predict.ParallelsCount)
+		}
+		topK := strconv.Itoa(k)
+		return &commandPredictor{fasttext.NewProcessPredictor(
+			ctx,
+			config.Fasttext.Command,
+			lambda.MapString(config.Fasttext.PredictArgs, func(s string) string {
+				if s == "{MODEL_PATH}" {
+					return modelPath
+				}
+				if s == "{TOP_K}" {
+					return topK
+				}
+				return s
+			}),
+			processCount,
+			time.Duration(config.Fasttext.TimeoutSeconds)*time.Second)}, nil
+	case FasttextEngineNative:
+		m, err := fasttext.LoadModel(modelPath)
+		if err != nil {
+			return nil, err
+		}
+		return &nativePredictor{m: m, k: k}, nil
+	}
+	return nil, errors.Errorf("bad fasttext engine: %q (command or native)", config.Fasttext.Engine)
+}
+
+// commandPredictor : 常駐させたfasttext predict-probで分類する
+type commandPredictor struct {
+	p *fasttext.ProcessPredictor
+}
+
+func (c *commandPredictor) Predict(ctx context.Context, tokens string) ([]fasttext.Prediction, error) {
+	return c.p.Predict(ctx, tokens)
+}
+
+func (c *commandPredictor) Close() error {
+	return c.p.Close()
+}
+
+// nativePredictor : Goで実装したfastTextで分類する
+type nativePredictor struct {
+	m *fasttext.Model
+	k int
+}
+
+func (n *nativePredictor) Predict(ctx context.Context, tokens string) ([]fasttext.Prediction, error) {
+	return n.m.Predict(tokens, n.k, 0), nil
+}
+
+func (n *nativePredictor) Close() error {
+	return nil
+}
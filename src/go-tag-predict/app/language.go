@@ -0,0 +1,67 @@
+package app
+
+import (
+	"unicode"
+)
+
+// DetectLanguageで判定する言語 (ISO 639-1)
+const (
+	LanguageJapanese = "ja"
+	LanguageEnglish  = "en"
+	LanguageChinese  = "zh"
+	LanguageKorean   = "ko"
+)
+
+// detectLanguageLetters : 言語の判定に使う文字数 (先頭から数える)
+const detectLanguageLetters = 10000
+
+// cjkLetterWeight : 漢字/かな/ハングル1文字を、ラテン文字何文字分とみなすか
+// 英語の1単語(平均5文字程度)は、日本語の2文字程度に相当するので、コードや英語の引用が多い日本語のページも日本語と判定する
+const cjkLetterWeight = 3
+
+// DetectLanguage : 文字種の統計から、テキストの言語を判定する
+//
+// 1. ハングルが最も多い => ko
+// 2. 漢字とかながラテン文字より多い => かなを含む場合はja, 含まない場合はzh
+// 3. ラテン文字が多い => en (英語以外のラテン文字の言語は区別しない)
+// 判定できない場合(文字がない, キリル文字など)は空文字を返す
+func DetectLanguage(s string) string {
+	kana, han, hangul, latin, letters := 0, 0, 0, 0, 0
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+		if letters++; letters >= detectLanguageLetters {
+			break
+		}
+	}
+	cjk := (kana + han) * cjkLetterWeight
+	switch {
+	case hangul > 0 && hangul*cjkLetterWeight >= cjk && hangul*cjkLetterWeight >= latin:
+		return LanguageKorean
+	case cjk > 0 && cjk >= latin:
+		// 中国語にかなはほとんど出てこないので、かなが漢字の10%以上あれば日本語とする
+		if kana*10 >= han {
+			return LanguageJapanese
+		}
+		return LanguageChinese
+	case latin > 0:
+		return LanguageEnglish
+	}
+	return ""
+}
+
+// languageToken : 判定した言語を、学習/分類の素性として加えるトークン
+func languageToken(lang string) string {
+	return "<lang:" + lang + ">"
+}
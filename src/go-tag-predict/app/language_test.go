@@ -0,0 +1,51 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+func ExampleDetectLanguage() {
+	for _, s := range []string{
+		"あのイーハトーヴォのすきとおった風、夏でも底に冷たさをもつ青いそら",
+		"Goのcontext.Contextでgoroutineをキャンセルする",
+		"The quick brown fox jumps over the lazy dog.",
+		"我能吞下玻璃而不伤身体",
+		"나는 유리를 먹을 수 있어요",
+		"Съешь же ещё этих мягких французских булок",
+		"12345 !?",
+	} {
+		fmt.Printf("%q\n", DetectLanguage(s))
+	}
+	// Output:
+	// "ja"
+	// "ja"
+	// "en"
+	// "zh"
+	// "ko"
+	// ""
+	// ""
+}
+
+func ExampleTokenizeLanguage() {
+	config := NewConfig()
+	config.Tokenizer.Name = "kagome"
+	config.Unicode = &UnicodeConfig{Stem: true}
+	config.Language = &LanguageConfig{Enabled: true, Default: "ja", Tokenizers: map[string]string{"en": "unicode"}, Feature: true}
+	if err := config.openTokenizers(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer config.Close()
+	fmt.Println(strings.Join(Tokenizers(), ","))
+
+	for _, s := range []string{"すもももももももものうち", "Running connections", "12345"} {
+		fmt.Println(TokenizeLanguage(context.Background(), config, s))
+	}
+	// Output:
+	// external,jumanpp,kagome,mecab,unicode
+	// [すもも も もも も もも の うち <lang:ja>] ja <nil>
+	// [run connect <lang:en>] en <nil>
+	// [12345 <lang:ja>] ja <nil>
+}
@@ -0,0 +1,176 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"go-tag-predict/asyncwriter"
+	"go-tag-predict/lambda"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PredictRecord : 1ページ分の分類結果
+type PredictRecord struct {
+	URL       string         `json:"url"`
+	FeedURL   string         `json:"feed_url"`
+	Title     string         `json:"title"`
+	Published *time.Time     `json:"published,omitempty"`
+	Tags      PredictResults `json:"tags"`
+	OOVRate   float64        `json:"oov_rate"`
+	Lang      string         `json:"lang,omitempty"`
+}
+
+// PredictSink : 分類結果の出力先
+//
+// 複数Goルーチンから同時にWriteされることを想定する
+type PredictSink interface {
+	Write(r *PredictRecord) error
+	Close() error
+}
+
+// 出力形式
+const (
+	OutputFormatJSONL = "jsonl"
+	OutputFormatCSV   = "csv"
+	OutputFormatTSV   = "tsv"
+)
+
+var predictRecordHeader = []string{"url", "feed_url", "title", "published", "tags", "probabilities", "oov_rate", "lang"}
+
+type predictSink struct {
+	aw     *asyncwriter.Writer
+	encode func(r *PredictRecord) (string, error)
+	closer io.Closer
+}
+
+// NewPredictSink : 出力形式を指定してPredictSinkを生成する
+func NewPredictSink(ctx context.Context, format string, w io.Writer, queueCount int) (PredictSink, error) {
+	s := &predictSink{}
+	if c, ok := w.(io.Closer); ok {
+		s.closer = c
+	}
+	bw := bufio.NewWriter(w)
+	switch format {
+	case "", OutputFormatJSONL:
+		s.encode = encodeJSONL
+	case OutputFormatCSV:
+		s.encode = encodeCSV
+		line, err := encodeCSVLine(predictRecordHeader)
+		if err != nil {
+			return nil, err
+		}
+		bw.WriteString(line)
+	case OutputFormatTSV:
+		s.encode = encodeTSV
+		bw.WriteString(encodeTSVLine(predictRecordHeader))
+	default:
+		return nil, errors.Errorf("bad output format: %q (jsonl, csv or tsv)", format)
+	}
+	s.aw = asyncwriter.NewWriter(ctx, bw, queueCount)
+	return s, nil
+}
+
+// OpenPredictSink : 設定に従ってPredictSinkを生成する
+// 出力先が未指定か"-"の場合は標準出力に出力する
+func OpenPredictSink(ctx context.Context, config *PredictConfig) (PredictSink, error) {
+	var w io.Writer = noCloseWriter{os.Stdout}
+	if config.OutputFilePath != "" && config.OutputFilePath != "-" {
+		f, err := os.Create(config.OutputFilePath)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		w = f
+	}
+	return NewPredictSink(ctx, config.OutputFormat, w, max(1, config.ParallelsCount))
+}
+
+func (s *predictSink) Write(r *PredictRecord) error {
+	if err := s.aw.Err(); err != nil {
+		return err
+	}
+	line, err := s.encode(r)
+	if err != nil {
+		return err
+	}
+	s.aw.WriteString(line)
+	return nil
+}
+
+// Close : 全ての書き込みを待って、出力先を閉じる
+// 書き込み/Flushに失敗していた場合は、出力が途中で切れているのでそのエラーを返す
+func (s *predictSink) Close() error {
+	s.aw.Close()
+	<-s.aw.Done()
+	err := s.aw.Err()
+	if s.closer != nil {
+		if e := s.closer.Close(); e != nil && err == nil {
+			err = errors.WithStack(e)
+		}
+	}
+	return err
+}
+
+func encodeJSONL(r *PredictRecord) (string, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(b) + "\n", nil
+}
+
+func encodeCSV(r *PredictRecord) (string, error) {
+	return encodeCSVLine(r.columns())
+}
+
+func encodeTSV(r *PredictRecord) (string, error) {
+	return encodeTSVLine(r.columns()), nil
+}
+
+func encodeCSVLine(columns []string) (string, error) {
+	buf := bytes.Buffer{}
+	w := csv.NewWriter(&buf)
+	if err := w.Write(columns); err != nil {
+		return "", errors.WithStack(err)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return buf.String(), nil
+}
+
+// TSVはクォートしないので、区切り文字と改行を空白に置き換える
+var tsvReplacer = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+
+func encodeTSVLine(columns []string) string {
+	return strings.Join(lambda.MapString(columns, tsvReplacer.Replace), "\t") + "\n"
+}
+
+// CSV/TSV用の列
+// タグと確率は、それぞれ空白区切りで同じ順番に並べる
+func (r *PredictRecord) columns() []string {
+	published := ""
+	if r.Published != nil {
+		published = r.Published.Format(time.RFC3339)
+	}
+	tags := make([]string, len(r.Tags))
+	probabilities := make([]string, len(r.Tags))
+	for i, p := range r.Tags {
+		tags[i] = p.Tag
+		probabilities[i] = strconv.FormatFloat(p.Probability, 'f', -1, 64)
+	}
+	return []string{r.URL, r.FeedURL, r.Title, published, strings.Join(tags, " "), strings.Join(probabilities, " "), strconv.FormatFloat(r.OOVRate, 'f', -1, 64), r.Lang}
+}
+
+// 標準出力をCloseしないためのラッパー
+type noCloseWriter struct {
+	io.Writer
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func ExampleNewPredictSink() {
+	published := time.Date(2017, 4, 27, 22, 19, 32, 0, time.UTC)
+	record := &PredictRecord{
+		URL:       "https://example.com/1",
+		FeedURL:   "https://feeds.pinboard.in/rss/popular/",
+		Title:     "title,\t\"quoted\"",
+		Published: &published,
+		Tags: PredictResults{
+			&PredictResult{Tag: "golang", Probability: 0.75},
+			&PredictResult{Tag: "にほんご", Probability: 0.125},
+		},
+		OOVRate: 0.25,
+		Lang:    "en",
+	}
+	for _, format := range []string{OutputFormatJSONL, OutputFormatCSV, OutputFormatTSV} {
+		buf := bytes.Buffer{}
+		sink, err := NewPredictSink(context.Background(), format, &buf, 1)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		sink.Write(record)
+		fmt.Println(sink.Close())
+		fmt.Print(buf.String())
+	}
+	_, err := NewPredictSink(context.Background(), "xml", &bytes.Buffer{}, 1)
+	fmt.Println(err)
+
+	// 書き込みに失敗した場合は、Closeでエラーを返す
+	sink, _ := NewPredictSink(context.Background(), OutputFormatJSONL, failWriter{}, 1)
+	sink.Write(record)
+	fmt.Println(sink.Close())
+
+	// Output:
+	// <nil>
+	// {"url":"https://example.com/1","feed_url":"https://feeds.pinboard.in/rss/popular/","title":"title,\t\"quoted\"","published":"2017-04-27T22:19:32Z","tags":[{"tag":"golang","probability":0.75},{"tag":"にほんご","probability":0.125}],"oov_rate":0.25,"lang":"en"}
+	// <nil>
+	// url,feed_url,title,published,tags,probabilities,oov_rate,lang
+	// https://example.com/1,https://feeds.pinboard.in/rss/popular/,"title,	""quoted""",2017-04-27T22:19:32Z,golang にほんご,0.75 0.125,0.25,en
+	// <nil>
+	// url	feed_url	title	published	tags	probabilities	oov_rate	lang
+	// https://example.com/1	https://feeds.pinboard.in/rss/popular/	title, "quoted"	2017-04-27T22:19:32Z	golang にほんご	0.75 0.125	0.25	en
+	// bad output format: "xml" (jsonl, csv or tsv)
+	// no space left on device
+}
+
+type failWriter struct{}
+
+func (w failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("no space left on device")
+}
@@ -0,0 +1,193 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Predictor : 学習済みのモデルでテキストを分類する
+//
+// tagid.txtとvocabid.txtは生成時に一度だけロードし、複数Goルーチンから共有して使う
+type Predictor struct {
+	config   *Config
+	vocab    TagID
+	idMap    map[int]string
+	fasttext labelPredictor
+}
+
+// NewPredictor : 学習結果をロードしてPredictorを生成する
+//
+// engineが"command"の場合は、fasttextをconfig.Fasttext.ProcessCount個常駐させて、ctxがキャンセルされるかCloseするまで使い回す
+// "native"の場合は、model.binをGoで読み込むので外部プログラムのfasttextを必要としない
+func NewPredictor(ctx context.Context, config *Config) (*Predictor, error) {
+	if err := checkTokenFilterFingerprint(config.GetTokenFilterPath(), config.TokenFilter, config.Language); err != nil {
+		return nil, err
+	}
+	labels, err := loadTagIDFile(config.GetTagIDPath())
+	if err != nil {
+		return nil, err
+	}
+	vocab, err := loadTagIDFile(config.GetVocabIDPath())
+	if err != nil {
+		return nil, err
+	}
+	// 分類中に未知語のIDが増え続けないように、読み取り専用にする
+	vocab.Freeze()
+	if _, ok := vocab.Lookup(UnknownTag); !ok && config.Predict.OOVPolicy == OOVPolicyUnk {
+		return nil, errors.New("oov_policy = \"unk\" needs <unk> in vocabid.txt. run supervised again with the same config")
+	}
+	modelPath := config.GetModelPathForPredict()
+	if _, err := os.Stat(modelPath); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ft, err := newLabelPredictor(ctx, config, modelPath, config.Predict.GetTopK())
+	if err != nil {
+		return nil, err
+	}
+	return &Predictor{config: config, vocab: vocab, idMap: labels.GetReverse(), fasttext: ft}, nil
+}
+
+func loadTagIDFile(filePath string) (TagID, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	return LoadTagID(bufio.NewReader(f))
+}
+
+// PredictOutput : 1文書分の分類結果
+type PredictOutput struct {
+	Tags PredictResults
+	// OOVRate : 学習時の辞書にないトークンの割合 (語彙のずれの目安)
+	OOVRate float64
+	// Lang : [language]が有効な場合に判定した言語
+	Lang string
+}
+
+// PredictURL : Webページを取得して分類する
+func (p *Predictor) PredictURL(ctx context.Context, rawurl string) (*PredictOutput, error) {
+	content, err := LoadWebContent(ctx, rawurl, p.config.CacheDirPath)
+	if err != nil {
+		return nil, err
+	}
+	return p.PredictText(ctx, content)
+}
+
+// PredictText : テキストを分類する
+func (p *Predictor) PredictText(ctx context.Context, text string) (*PredictOutput, error) {
+	tokens, lang, err := TokenizeLanguage(ctx, p.config, text)
+	if err != nil {
+		return nil, err
+	}
+	encoded, oov := encodeTokens(p.vocab, tokens, p.config.Predict.OOVPolicy)
+	res := &PredictOutput{Tags: PredictResults{}, Lang: lang}
+	// 空行はfastTextのバージョンによって出力が異なるので、分類せずにタグなしとする
+	if len(encoded) > 0 {
+		res.Tags, err = p.predict(ctx, strings.Join(encoded, " "))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(tokens) > 0 {
+		res.OOVRate = float64(oov) / float64(len(tokens))
+	}
+	return res, nil
+}
+
+// 未知語の扱い
+const (
+	// OOVPolicyDrop : 未知語を取り除く
+	OOVPolicyDrop = "drop"
+	// OOVPolicyUnk : 未知語をまとめてUnknownIDに置き換える
+	// 学習時にunk_min_count未満の単語をUnknownIDにまとめるので、<unk>のベクトルが使われる
+	OOVPolicyUnk = "unk"
+	// OOVPolicyKeep : 未知語を表層形のまま残す
+	OOVPolicyKeep = "keep"
+)
+
+// encodeTokens : トークンをfastTextの入力用のIDに変換する
+// 辞書にないトークンはpolicyに従って処理し、その数を返す
+func encodeTokens(t TagID, tokens []string, policy string) ([]string, int) {
+	res := make([]string, 0, len(tokens))
+	oov := 0
+	for _, token := range tokens {
+		if id, ok := t.Lookup(token); ok {
+			res = append(res, strconv.Itoa(id))
+			continue
+		}
+		oov++
+		switch policy {
+		case OOVPolicyUnk:
+			res = append(res, strconv.Itoa(UnknownID))
+		case OOVPolicyKeep:
+			// 数字だけのトークンは辞書のIDと区別できないので、先頭に"#"を付ける
+			if isDigits(token) {
+				token = "#" + token
+			}
+			res = append(res, token)
+		}
+	}
+	return res, oov
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Predictor) predict(ctx context.Context, tokens string) (PredictResults, error) {
+	predictions, err := p.fasttext.Predict(ctx, tokens)
+	if err != nil {
+		return nil, err
+	}
+	res := make(PredictResults, 0, len(predictions))
+	cumulative := 0.0
+	for _, prediction := range predictions {
+		id, err := parseLabelID(prediction.Label)
+		if err != nil {
+			return nil, err
+		}
+		if prediction.Probability < p.config.Predict.MinProbability {
+			continue
+		}
+		if tag, ok := p.idMap[id]; ok {
+			res = append(res, &PredictResult{Tag: tag, Probability: prediction.Probability})
+		}
+		// 確率の高い順に、累積確率がしきい値に達するまでのタグを採用する
+		cumulative += prediction.Probability
+		if p.config.Predict.CumulativeProbability > 0 && cumulative >= p.config.Predict.CumulativeProbability {
+			break
+		}
+	}
+	return res, nil
+}
+
+// parseLabelID : "__label__1"形式のラベルからIDを取得する
+func parseLabelID(label string) (int, error) {
+	if !strings.HasPrefix(label, "__label__") {
+		return 0, errors.New("bad fasttext label: " + label)
+	}
+	id, err := strconv.Atoi(label[len("__label__"):])
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return id, nil
+}
+
+// Close : 常駐しているfasttextを終了する
+func (p *Predictor) Close() error {
+	return p.fasttext.Close()
+}
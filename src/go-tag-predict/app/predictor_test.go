@@ -0,0 +1,62 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"go-tag-predict/fasttext"
+	"strings"
+)
+
+type fakeLabelPredictor struct {
+	predictions []fasttext.Prediction
+}
+
+func (f *fakeLabelPredictor) Predict(ctx context.Context, tokens string) ([]fasttext.Prediction, error) {
+	return f.predictions, nil
+}
+func (f *fakeLabelPredictor) Close() error {
+	return nil
+}
+
+func ExamplePredictor() {
+	ft := &fakeLabelPredictor{[]fasttext.Prediction{
+		{Label: "__label__1", Probability: 0.5},
+		{Label: "__label__2", Probability: 0.3},
+		{Label: "__label__3", Probability: 0.15},
+		{Label: "__label__4", Probability: 0.05},
+	}}
+	idMap := map[int]string{1: "golang", 2: "programming", 3: "web", 4: "toread"}
+	for _, config := range []*PredictConfig{
+		{},
+		{MinProbability: 0.1},
+		{CumulativeProbability: 0.8},
+		{CumulativeProbability: 0.85, MinProbability: 0.2},
+	} {
+		p := &Predictor{config: &Config{Predict: config}, idMap: idMap, fasttext: ft}
+		res, err := p.predict(context.Background(), "")
+		fmt.Print(err)
+		for _, r := range res {
+			fmt.Print(" ", r.Tag)
+		}
+		fmt.Println()
+	}
+	// Output:
+	// <nil> golang programming web toread
+	// <nil> golang programming web
+	// <nil> golang programming
+	// <nil> golang programming
+}
+
+func Example_encodeTokens() {
+	t, _ := LoadTagID(strings.NewReader("0\t<unk>\t4\n1\tgolang\t3\n5\tweb\t1\n"))
+	t.Freeze()
+	tokens := []string{"golang", "unknown", "web", "42"}
+	for _, policy := range []string{OOVPolicyDrop, OOVPolicyUnk, OOVPolicyKeep} {
+		encoded, oov := encodeTokens(t, tokens, policy)
+		fmt.Println(policy, encoded, oov)
+	}
+	// Output:
+	// drop [1 5] 2
+	// unk [1 0 5 0] 2
+	// keep [1 unknown 5 #42] 2
+}
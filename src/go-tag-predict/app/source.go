@@ -0,0 +1,116 @@
+package app
+
+import (
+	"context"
+	"go-tag-predict/importer"
+	"go-tag-predict/webservice/pinboard"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+// 学習ソース
+const (
+	// LearningSourceFile : learning_source_fileのエクスポートファイル (形式はlearning_source_format)
+	LearningSourceFile = "file"
+	// LearningSourcePinboard : Pinboard APIと同期したミラーファイル
+	LearningSourcePinboard = "pinboard"
+)
+
+// syncLearningSource : 学習ソースがpinboardの場合は、Pinboard APIからミラーファイルに同期する
+// 1回のコマンドで学習ソースを何度読んでも、APIを呼ぶのはこの1回だけにする
+func syncLearningSource(ctx context.Context, config *Config, logger *zap.Logger) error {
+	if config.Supervised.LearningSource != LearningSourcePinboard {
+		return nil
+	}
+	return syncPinboard(ctx, config, logger)
+}
+
+// loadLearningSource : 学習に使うブックマークを取得する
+// include_private, time_from, time_toの条件に合わないブックマークは除外する
+func loadLearningSource(config *Config) (pinboard.PostIterator, error) {
+	f, err := newPostFilter(config)
+	if err != nil {
+		return nil, err
+	}
+	itr, err := openLearningSource(config)
+	if err != nil {
+		return nil, err
+	}
+	f.PostIterator = itr
+	return f, nil
+}
+
+// openLearningSource : 学習ソースの全てのブックマークを取得する
+// pinboardの場合は、syncLearningSourceで同期済みのミラーファイルを読む
+func openLearningSource(config *Config) (pinboard.PostIterator, error) {
+	switch config.Supervised.LearningSource {
+	case "", LearningSourceFile:
+		return importer.LoadFile(config.Supervised.LearningSourceFilePath, config.Supervised.LearningSourceFormat)
+	case LearningSourcePinboard:
+		return pinboard.LoadFile(config.Pinboard.MirrorFilePath)
+	}
+	return nil, errors.Errorf("bad learning_source: %q (file or pinboard)", config.Supervised.LearningSource)
+}
+
+// postFilter : 学習に使わないブックマークを除外する
+// from, toはゼロ値でない場合だけ使う (from <= Time < to)。日時が不明なブックマークは、範囲を指定した場合は除外する
+type postFilter struct {
+	pinboard.PostIterator
+	includePrivate bool
+	from           time.Time
+	to             time.Time
+}
+
+func newPostFilter(config *Config) (*postFilter, error) {
+	from, to, err := config.Supervised.GetTimeRange()
+	if err != nil {
+		return nil, err
+	}
+	return &postFilter{includePrivate: config.Supervised.IncludePrivate, from: from, to: to}, nil
+}
+
+func (f *postFilter) Next() (*pinboard.Post, error) {
+	for {
+		post, err := f.PostIterator.Next()
+		if post == nil || err != nil {
+			return post, err
+		}
+		if f.accept(post) {
+			return post, nil
+		}
+	}
+}
+
+func (f *postFilter) accept(post *pinboard.Post) bool {
+	if post.Private && !f.includePrivate {
+		return false
+	}
+	if !f.from.IsZero() && (post.Time.IsZero() || post.Time.Before(f.from)) {
+		return false
+	}
+	if !f.to.IsZero() && (post.Time.IsZero() || !post.Time.Before(f.to)) {
+		return false
+	}
+	return true
+}
+
+// syncPinboard : Pinboard APIからミラーファイルに同期する
+func syncPinboard(ctx context.Context, config *Config, logger *zap.Logger) error {
+	token := config.Pinboard.GetToken()
+	if token == "" {
+		return errors.New("pinboard token is required. set [pinboard] token or PINBOARD_TOKEN")
+	}
+	c := pinboard.NewClient(token)
+	c.BaseURL = config.Pinboard.APIURL
+	n, err := pinboard.Sync(ctx, c, config.Pinboard.MirrorFilePath)
+	if err != nil {
+		return err
+	}
+	logger.Info("pinboard sync",
+		zap.String("mirror", config.Pinboard.MirrorFilePath),
+		zap.Int("updated", n))
+	return nil
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"fmt"
+	"go-tag-predict/webservice/pinboard"
+	"strings"
+)
+
+func Example_postFilter() {
+	body := `<posts>
+	<post href="http://1" time="2016-12-31T23:00:00Z" description="a" tag="go" shared="yes" />
+	<post href="http://2" time="2017-01-01T00:00:00Z" description="b" tag="go" shared="no" />
+	<post href="http://3" time="2017-06-30T12:00:00Z" description="c" tag="go" shared="yes" />
+	<post href="http://4" time="2017-07-01T00:00:00Z" description="d" tag="go" />
+	<post href="http://5" description="e" tag="go" />
+</posts>`
+	config := NewConfig()
+	config.Supervised = &SupervisedConfig{}
+	for _, c := range []*SupervisedConfig{
+		{},
+		{IncludePrivate: true},
+		{IncludePrivate: true, TimeFrom: "2017-01-01T00:00:00Z", TimeTo: "2017-07-01T00:00:00Z"},
+		{TimeFrom: "2017-01-01T09:00:00+09:00"},
+	} {
+		config.Supervised = c
+		f, err := newPostFilter(config)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		f.PostIterator, _ = pinboard.Parse(strings.NewReader(body))
+		hrefs := []string{}
+		for {
+			post, _ := f.Next()
+			if post == nil {
+				break
+			}
+			hrefs = append(hrefs, post.Href)
+		}
+		fmt.Println(hrefs)
+	}
+
+	config.Supervised = &SupervisedConfig{TimeTo: "2017/07/01"}
+	_, err := newPostFilter(config)
+	fmt.Println(strings.HasPrefix(err.Error(), "bad time_to"))
+
+	// Output:
+	// [http://1 http://3 http://4 http://5]
+	// [http://1 http://2 http://3 http://4 http://5]
+	// [http://2 http://3]
+	// [http://3 http://4]
+	// true
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"go-tag-predict/lambda"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -12,51 +13,96 @@ import (
 )
 
 // TagID : stringのタグをintに変換する
+//
+// 学習時はラベル(tagid.txt)と本文の単語(vocabid.txt)で別々のTagIDを使うので、
+// 同じ文字列でもラベルと単語のIDは独立している
 type TagID interface {
 	GetID(tag string) int
 	GetIDs(ar []string) []int
+	Lookup(tag string) (int, bool)
+	Count(tag string) int
+	SetCount(tag string, count int)
+	Merge(from []string, to string) (map[int]int, error)
+	Drop(tags []string) (map[int]int, error)
+	Prune(minCount int) map[int]int
+	Freeze()
 	Serialize(w io.Writer)
 	GetReverse() map[int]string
 }
 type tagID struct {
-	mutex  sync.Mutex
-	seed   int
-	tagMap map[string]int
+	mutex    sync.Mutex
+	seed     int
+	frozen   bool
+	tagMap   map[string]int
+	countMap map[string]int
 }
 
+// UnknownID : 読み取り専用のTagIDで、未登録のタグに割り当てるID
+// ※IDは1から振るので、0は既存のタグと重ならない
+const UnknownID = 0
+
+// UnknownTag : Pruneで出現回数の少ないタグをまとめるタグ (IDはUnknownID)
+const UnknownTag = "<unk>"
+
 // NewTagID : コンストラクタ
 func NewTagID() TagID {
-	return &tagID{tagMap: make(map[string]int, 1024*10)}
+	return &tagID{tagMap: make(map[string]int, 1024*10), countMap: make(map[string]int, 1024*10)}
 }
 
 // LoadTagID : TagIDをロード
+//
+// 1行が"ID\tタグ\t出現回数"の形式 (出現回数がない古い形式も読み込める)
 func LoadTagID(r io.Reader) (TagID, error) {
 	scanner := bufio.NewScanner(r)
 	tagMap := make(map[string]int, 1024*10)
+	countMap := make(map[string]int, 1024*10)
+	seed := 0
 	lineNo := 0
 	for scanner.Scan() {
 		lineNo++
 		ar := strings.SplitN(scanner.Text(), "\t", 2)
+		if len(ar) != 2 {
+			return nil, errors.New("parse error. line: " + strconv.Itoa(lineNo))
+		}
 		i, err := strconv.Atoi(ar[0])
 		if err != nil {
 			return nil, errors.Wrap(err, "parse error. line: "+strconv.Itoa(lineNo))
 		}
-		tagMap[ar[1]] = i
+		// タグはタブを含むことがあるので、出現回数は最後のタブの後ろが数値の場合だけ読み取る
+		// (出現回数のない以前の形式は、最初のタブの後ろ全てがタグ)
+		tag := ar[1]
+		if j := strings.LastIndex(tag, "\t"); j >= 0 {
+			if count, err := strconv.Atoi(tag[j+1:]); err == nil {
+				tag = tag[:j]
+				countMap[tag] = count
+			}
+		}
+		tagMap[tag] = i
+		if i > seed {
+			seed = i
+		}
 	}
 	if err := scanner.Err(); err != nil {
 		return nil, errors.WithStack(err)
 	}
-	return &tagID{tagMap: tagMap}, nil
+	return &tagID{seed: seed, tagMap: tagMap, countMap: countMap}, nil
 }
 
 func (t *tagID) getID(tag string) int {
 	tag = strings.Replace(tag, "\n", "", -1)
-	if i, ok := t.tagMap[tag]; ok {
-		return i
+	i, ok := t.tagMap[tag]
+	if !ok {
+		if t.frozen {
+			return UnknownID
+		}
+		t.seed++
+		i = t.seed
+		t.tagMap[tag] = i
+	}
+	if !t.frozen {
+		t.countMap[tag]++
 	}
-	t.seed++
-	t.tagMap[tag] = t.seed
-	return t.seed
+	return i
 }
 
 // GetID : stringのタグをintに変換する
@@ -76,20 +122,138 @@ func (t *tagID) GetIDs(ar []string) []int {
 	})
 }
 
-// Serialize : シリアライズ
+// Lookup : 登録済みのタグのIDを取得する (未登録のタグは追加しない)
+func (t *tagID) Lookup(tag string) (int, bool) {
+	defer t.mutex.Unlock()
+	t.mutex.Lock()
+	i, ok := t.tagMap[strings.Replace(tag, "\n", "", -1)]
+	return i, ok
+}
+
+// Count : 学習時の出現回数を取得する
+func (t *tagID) Count(tag string) int {
+	defer t.mutex.Unlock()
+	t.mutex.Lock()
+	return t.countMap[strings.Replace(tag, "\n", "", -1)]
+}
+
+// SetCount : 出現回数を変更する (未登録のタグは何もしない)
+func (t *tagID) SetCount(tag string, count int) {
+	defer t.mutex.Unlock()
+	t.mutex.Lock()
+	if _, ok := t.tagMap[tag]; ok {
+		t.countMap[tag] = count
+	}
+}
+
+// Merge : fromのタグをtoにまとめる。出現回数は合計する
+// toが未登録の場合は、from[0]のIDをtoの名前に変える (fromが1つならタグ名の変更)
+// 変更前のID => 変更後のIDの対応を返す (IDが変わらないタグは含まない)
+func (t *tagID) Merge(from []string, to string) (map[int]int, error) {
+	defer t.mutex.Unlock()
+	t.mutex.Lock()
+	for _, tag := range from {
+		if _, ok := t.tagMap[tag]; !ok {
+			return nil, errors.Errorf("unknown tag: %q", tag)
+		}
+	}
+	toID, ok := t.tagMap[to]
+	if !ok {
+		toID = t.tagMap[from[0]]
+		t.tagMap[to] = toID
+	}
+	remap := make(map[int]int, len(from))
+	for _, tag := range from {
+		id, ok := t.tagMap[tag]
+		if tag == to || !ok {
+			continue
+		}
+		if id != toID {
+			remap[id] = toID
+		}
+		t.countMap[to] += t.countMap[tag]
+		delete(t.tagMap, tag)
+		delete(t.countMap, tag)
+	}
+	return remap, nil
+}
+
+// Drop : タグを削除する
+// 削除したタグのID => UnknownIDの対応を返す
+func (t *tagID) Drop(tags []string) (map[int]int, error) {
+	defer t.mutex.Unlock()
+	t.mutex.Lock()
+	remap := make(map[int]int, len(tags))
+	for _, tag := range tags {
+		id, ok := t.tagMap[tag]
+		if !ok {
+			return nil, errors.Errorf("unknown tag: %q", tag)
+		}
+		remap[id] = UnknownID
+	}
+	for _, tag := range tags {
+		delete(t.tagMap, tag)
+		delete(t.countMap, tag)
+	}
+	return remap, nil
+}
+
+// Prune : 出現回数がminCount未満のタグを削除して、UnknownTagにまとめる。出現回数は合計する
+// 削除したタグのID => UnknownIDの対応を返す
+func (t *tagID) Prune(minCount int) map[int]int {
+	defer t.mutex.Unlock()
+	t.mutex.Lock()
+	remap := make(map[int]int, 1024)
+	count := 0
+	for tag, id := range t.tagMap {
+		if id == UnknownID || (tag != UnknownTag && t.countMap[tag] >= minCount) {
+			continue
+		}
+		remap[id] = UnknownID
+		count += t.countMap[tag]
+		delete(t.tagMap, tag)
+		delete(t.countMap, tag)
+	}
+	t.tagMap[UnknownTag] = UnknownID
+	t.countMap[UnknownTag] += count
+	return remap
+}
+
+// Freeze : 読み取り専用にする
+// 以降、未登録のタグにはIDを振らずにUnknownIDを返す
+func (t *tagID) Freeze() {
+	defer t.mutex.Unlock()
+	t.mutex.Lock()
+	t.frozen = true
+}
+
+// Serialize : シリアライズ (ID順)
 func (t *tagID) Serialize(w io.Writer) {
+	defer t.mutex.Unlock()
+	t.mutex.Lock()
+	tags := make([]string, 0, len(t.tagMap))
+	for k := range t.tagMap {
+		tags = append(tags, k)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return t.tagMap[tags[i]] < t.tagMap[tags[j]]
+	})
 	bw := bufio.NewWriterSize(w, 1024*100)
 	defer bw.Flush()
-	for k, v := range t.tagMap {
-		bw.WriteString(strconv.Itoa(v))
+	for _, k := range tags {
+		bw.WriteString(strconv.Itoa(t.tagMap[k]))
 		bw.WriteString("\t")
 		bw.WriteString(k)
+		bw.WriteString("\t")
+		bw.WriteString(strconv.Itoa(t.countMap[k]))
 		bw.WriteString("\n")
 	}
 }
 
 // GetReverse : int => string変換表を取得する
 func (t *tagID) GetReverse() map[int]string {
+	defer t.mutex.Unlock()
+	t.mutex.Lock()
 	idMap := make(map[int]string, len(t.tagMap))
 	for k, v := range t.tagMap {
 		idMap[v] = k
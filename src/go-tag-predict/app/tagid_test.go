@@ -0,0 +1,56 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+func ExampleLoadTagID() {
+	t, err := LoadTagID(strings.NewReader("1\tgolang\t3\n2\tプログラミング\t1\n5\tweb\n"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	t.Freeze()
+	fmt.Println(t.GetIDs([]string{"golang", "web", "unknown"}))
+	fmt.Println(t.Lookup("unknown"))
+	fmt.Println(t.Count("golang"), t.Count("web"), t.Count("unknown"))
+
+	// タブを含むタグ
+	t, _ = LoadTagID(strings.NewReader("1\tgo\tlang\t3\n2\ta\tb\n"))
+	fmt.Println(t.Lookup("go\tlang"))
+	fmt.Println(t.Lookup("a\tb"))
+	fmt.Println(t.Count("go\tlang"), t.Count("a\tb"))
+
+	_, err = LoadTagID(strings.NewReader("1\tgolang\nbroken\n"))
+	fmt.Println(err)
+	// Output:
+	// [1 5 0]
+	// 0 false
+	// 3 0 0
+	// 1 true
+	// 2 true
+	// 3 0
+	// parse error. line: 2
+}
+
+func ExampleNewTagID() {
+	labels := NewTagID()
+	vocab := NewTagID()
+	// 同じ文字列でも、ラベルと単語のIDは独立している
+	fmt.Println(labels.GetID("go"), labels.GetID("web"), labels.GetID("go"))
+	fmt.Println(vocab.GetIDs([]string{"web", "の", "go", "の"}))
+	fmt.Println(labels.GetReverse())
+
+	buf := bytes.Buffer{}
+	vocab.Serialize(&buf)
+	fmt.Print(buf.String())
+	// Output:
+	// 1 2 1
+	// [1 2 3 2]
+	// map[1:go 2:web]
+	// 1	web	1
+	// 2	の	2
+	// 3	go	1
+}
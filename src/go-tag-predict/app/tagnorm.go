@@ -0,0 +1,141 @@
+package app
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// TagNormalizer : 学習前にブックマークのタグを正規化/除外する
+//
+// 1. 前後の空白を除去
+// 2. Unicode NFKC正規化 (nfkc)
+// 3. 小文字に統一 (case_fold)
+// 4. 別名を正式なタグに置き換え (aliases)
+// 5. denyのglobパターンに一致するタグを除外
+// 6. Limitで設定した場合は、出現回数がmin_tag_count未満 or 上位max_tags個に入らないタグを除外
+type TagNormalizer struct {
+	caseFold bool
+	nfkc     bool
+	aliases  map[string]string
+	deny     []string
+	// allowed : Limitで残すタグ (nilの場合は全て残す)
+	allowed map[string]bool
+}
+
+// NewTagNormalizer : コンストラクタ
+// aliasesとdenyも、タグと同じ方法で正規化してから比較する
+func NewTagNormalizer(config *TagsConfig) (*TagNormalizer, error) {
+	n := &TagNormalizer{caseFold: config.CaseFold, nfkc: config.NFKC}
+	n.aliases = make(map[string]string, len(config.Aliases))
+	for alias, tag := range config.Aliases {
+		n.aliases[n.fold(alias)] = n.fold(tag)
+	}
+	n.deny = make([]string, 0, len(config.Deny))
+	for _, pattern := range config.Deny {
+		pattern = n.fold(pattern)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.Wrap(err, "bad tags deny: "+pattern)
+		}
+		n.deny = append(n.deny, pattern)
+	}
+	return n, nil
+}
+
+// fold : 空白の除去, NFKC正規化, 小文字への統一
+func (n *TagNormalizer) fold(tag string) string {
+	tag = strings.TrimSpace(tag)
+	if n.nfkc {
+		tag = norm.NFKC.String(tag)
+	}
+	if n.caseFold {
+		tag = strings.ToLower(tag)
+	}
+	return tag
+}
+
+// normalize : 1つのタグを正規化する。除外するタグは空文字を返す
+func (n *TagNormalizer) normalize(tag string) string {
+	tag = n.fold(tag)
+	if t, ok := n.aliases[tag]; ok {
+		tag = t
+	}
+	if tag == "" {
+		return ""
+	}
+	for _, pattern := range n.deny {
+		if ok, _ := path.Match(pattern, tag); ok {
+			return ""
+		}
+	}
+	return tag
+}
+
+// Normalize : タグを正規化して、除外するタグと重複を取り除く
+func (n *TagNormalizer) Normalize(tags []string) []string {
+	res := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = n.normalize(tag)
+		if tag == "" || containsString(res, tag) {
+			continue
+		}
+		res = append(res, tag)
+	}
+	return n.filter(res)
+}
+
+// filter : Limitで残すタグだけにする (正規化済みのタグを渡す)
+func (n *TagNormalizer) filter(tags []string) []string {
+	if n.allowed == nil {
+		return tags
+	}
+	res := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if n.allowed[tag] {
+			res = append(res, tag)
+		}
+	}
+	return res
+}
+
+// Limit : 正規化済みのタグの出現回数から、学習に使うタグを決める
+// 出現回数がminCount未満のタグを除き、maxTags > 0の場合は出現回数の多い順(同数はタグ名順)にmaxTags個まで残す
+// 残したタグの数を返す
+func (n *TagNormalizer) Limit(counts map[string]int, minCount int, maxTags int) int {
+	tags := make([]string, 0, len(counts))
+	for tag, c := range counts {
+		if c >= minCount {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if counts[tags[i]] != counts[tags[j]] {
+			return counts[tags[i]] > counts[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+	if maxTags > 0 && len(tags) > maxTags {
+		tags = tags[:maxTags]
+	}
+	n.allowed = make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		n.allowed[tag] = true
+	}
+	return len(tags)
+}
+
+// needsCount : 学習に使うタグを決めるために、事前にタグの出現回数を数える必要があるか
+func (c *TagsConfig) needsCount() bool {
+	return c.MinTagCount > 1 || c.MaxTags > 0
+}
+
+// countTags : タグごとに、そのタグが付いているブックマークの数を数える
+func countTags(counts map[string]int, tags []string) {
+	for _, tag := range tags {
+		counts[tag]++
+	}
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"fmt"
+)
+
+func ExampleTagNormalizer() {
+	tn, err := NewTagNormalizer(&TagsConfig{
+		CaseFold: true,
+		NFKC:     true,
+		Aliases:  map[string]string{"Golang": "go", "ｊｓ": "JavaScript"},
+		Deny:     []string{"via:*", "toread", "IFTTT"},
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	posts := [][]string{
+		{"golang", "Go", " GO ", "via:twitter"},
+		{"ＪＳ", "javascript", "toread", "ifttt"},
+		{"go", "web"},
+		{"Web", "css"},
+	}
+	counts := map[string]int{}
+	for _, tags := range posts {
+		tags = tn.Normalize(tags)
+		fmt.Println(tags)
+		countTags(counts, tags)
+	}
+
+	fmt.Println(tn.Limit(counts, 2, 0))
+	for _, tags := range posts {
+		fmt.Println(tn.Normalize(tags))
+	}
+	fmt.Println(tn.Limit(counts, 1, 2))
+	fmt.Println(tn.Normalize([]string{"css", "javascript", "web"}))
+
+	_, err = NewTagNormalizer(&TagsConfig{Deny: []string{"[a-"}})
+	fmt.Println(err)
+
+	// Output:
+	// [go]
+	// [javascript]
+	// [go web]
+	// [web css]
+	// 2
+	// [go]
+	// []
+	// [go web]
+	// [web]
+	// 2
+	// [web]
+	// bad tags deny: [a-: syntax error in pattern
+}
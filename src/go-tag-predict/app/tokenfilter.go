@@ -0,0 +1,277 @@
+package app
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// 数字とURLをまとめたトークン
+const (
+	// TokenNumber : collapse_numbersで数字を置き換えるトークン
+	TokenNumber = "<num>"
+	// TokenURL : collapse_urlsでURLを置き換えるトークン
+	TokenURL = "<url>"
+)
+
+// urlPlaceholder : collapse_urlsで、分かち書きの前にテキストのURLを置き換える単語
+// 分かち書きするとURLは記号で分割されるので、どのTokenizerでも1単語になる英小文字に置き換えてから、TokenURLに変換する
+const urlPlaceholder = "xxurlxx"
+
+// urlPattern : テキストの中のURL (全角文字や空白の手前まで)
+var urlPattern = regexp.MustCompile(`(?i)(?:https?://|www\.)[0-9a-z\-._~:/?#\[\]@!$&'()*+,;=%]+`)
+
+// TokenFilter : 1つのトークンを変換する。空文字を返したトークンは取り除く
+type TokenFilter func(token string) string
+
+// TokenFilterChain : 分かち書きしたトークンに順番に適用するTokenFilterと、言語ごとのストップワード
+//
+// 学習と分類で同じ処理になるように、Tokenizeの中で適用する
+type TokenFilterChain struct {
+	filters      []TokenFilter
+	collapseURLs bool
+	// stopwords : 言語 => ストップワード
+	// ""には、言語が分からない場合に使う全ての言語のストップワードをまとめる
+	stopwords map[string]map[string]bool
+}
+
+// NewTokenFilterChain : [token_filter]の設定からTokenFilterChainを生成する
+// 処理の順番は、NFKC正規化 => 小文字化 => URL/数字をまとめる => 記号の除去 => 長さの制限 => ストップワードの除去
+// ※URLは分かち書きで分割されるので、分かち書きの前にReplaceURLsでも置き換える
+func NewTokenFilterChain(config *TokenFilterConfig) (*TokenFilterChain, error) {
+	chain := []TokenFilter{func(token string) string {
+		return strings.TrimSpace(token)
+	}}
+	if config.NFKC {
+		chain = append(chain, norm.NFKC.String)
+	}
+	if config.Lowercase {
+		chain = append(chain, strings.ToLower)
+	}
+	if config.CollapseURLs {
+		chain = append(chain, func(token string) string {
+			if token == urlPlaceholder || isURLToken(token) {
+				return TokenURL
+			}
+			return token
+		})
+	}
+	if config.CollapseNumbers {
+		chain = append(chain, func(token string) string {
+			if isNumberToken(token) {
+				return TokenNumber
+			}
+			return token
+		})
+	}
+	if config.RemovePunct {
+		chain = append(chain, func(token string) string {
+			if isPunctToken(token) {
+				return ""
+			}
+			return token
+		})
+	}
+	if config.MinLength > 0 || config.MaxLength > 0 {
+		chain = append(chain, func(token string) string {
+			if token == TokenNumber || token == TokenURL {
+				return token
+			}
+			l := utf8.RuneCountInString(token)
+			if l < config.MinLength || (config.MaxLength > 0 && l > config.MaxLength) {
+				return ""
+			}
+			return token
+		})
+	}
+	stopwords, err := loadStopwords(config)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenFilterChain{filters: chain, collapseURLs: config.CollapseURLs, stopwords: stopwords}, nil
+}
+
+// ReplaceURLs : collapse_urlsの場合は、分かち書きする前のテキストのURLをurlPlaceholderに置き換える
+// URLの後ろの句読点や閉じ括弧は、文の一部として残す
+func (c *TokenFilterChain) ReplaceURLs(s string) string {
+	if !c.collapseURLs {
+		return s
+	}
+	return urlPattern.ReplaceAllStringFunc(s, func(u string) string {
+		trimmed := strings.TrimRight(u, ".,;:!?)'")
+		return " " + urlPlaceholder + " " + u[len(trimmed):]
+	})
+}
+
+// Apply : トークンを変換して、空になったトークンとlangのストップワードを取り除く
+// langが""の場合は、全ての言語のストップワードを取り除く
+func (c *TokenFilterChain) Apply(tokens []string, lang string) []string {
+	stopwords := c.stopwords[lang]
+	res := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		for _, f := range c.filters {
+			if token = f(token); token == "" {
+				break
+			}
+		}
+		if token != "" && !stopwords[token] {
+			res = append(res, token)
+		}
+	}
+	return res
+}
+
+// isURLToken : http(s)://で始まるか、www.で始まるトークン
+func isURLToken(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "www.")
+}
+
+// isNumberToken : 数字と区切り文字(,.)だけのトークン (先頭は数字)
+func isNumberToken(s string) bool {
+	if s == "" || !unicode.IsDigit([]rune(s)[0]) {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsDigit(r) && r != ',' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// isPunctToken : 句読点と記号だけのトークン (、。「」!?など)
+func isPunctToken(s string) bool {
+	for _, r := range s {
+		if !unicode.IsPunct(r) && !unicode.IsSymbol(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// loadStopwords : 言語ごとにストップワードのファイルを読み込む
+// 1行に1単語。空行と#で始まる行は無視する。nfkc, lowercaseを指定した場合は、トークンと同じように変換する
+// ""には全ての言語のストップワードをまとめる
+func loadStopwords(config *TokenFilterConfig) (map[string]map[string]bool, error) {
+	res := make(map[string]map[string]bool, len(config.Stopwords)+1)
+	if len(config.Stopwords) == 0 {
+		return res, nil
+	}
+	all := make(map[string]bool, 1024)
+	res[""] = all
+	for lang, filePath := range config.Stopwords {
+		stopwords := make(map[string]bool, 1024)
+		res[lang] = stopwords
+		f, err := os.Open(filePath)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			word := strings.TrimSpace(scanner.Text())
+			if word == "" || strings.HasPrefix(word, "#") {
+				continue
+			}
+			if config.NFKC {
+				word = norm.NFKC.String(word)
+			}
+			if config.Lowercase {
+				word = strings.ToLower(word)
+			}
+			stopwords[word] = true
+			all[word] = true
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, filePath)
+		}
+	}
+	return res, nil
+}
+
+// tokenFilterVersion : トークンの処理の実装を変えた場合に上げて、以前の学習結果で分類しないようにする
+// 2: ストップワードを言語ごとに使い分ける
+// 3: collapse_urlsで、分かち書きの前にURLを置き換える
+const tokenFilterVersion = "3"
+
+// tokenFilterFingerprint : 学習時と分類時のトークンの処理が同じか確認するための値
+// [token_filter]の設定と、ストップワードのファイルの内容のハッシュ値 (ファイルの場所は含めない)
+// [language]が有効な場合は、その設定も含める
+func tokenFilterFingerprint(config *TokenFilterConfig, lang *LanguageConfig) (string, error) {
+	h := sha1.New()
+	h.Write([]byte(tokenFilterVersion + "\n"))
+	b, err := json.Marshal(config)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	h.Write(b)
+	if lang != nil && lang.Enabled {
+		b, err := json.Marshal(lang)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		h.Write([]byte("\n"))
+		h.Write(b)
+	}
+	langs := make([]string, 0, len(config.Stopwords))
+	for lang := range config.Stopwords {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		h.Write([]byte("\n" + lang + "\n"))
+		f, err := os.Open(config.Stopwords[lang])
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// saveTokenFilterFingerprint : 学習時のトークンの処理を記録する
+func saveTokenFilterFingerprint(filePath string, config *TokenFilterConfig, lang *LanguageConfig) error {
+	fp, err := tokenFilterFingerprint(config, lang)
+	if err != nil {
+		return err
+	}
+	return errors.WithStack(ioutil.WriteFile(filePath, []byte(fp+"\n"), 0600))
+}
+
+// checkTokenFilterFingerprint : 分類時のトークンの処理が、学習時と同じか確認する
+// 記録がない(以前のバージョンで学習した)場合は確認しない
+func checkTokenFilterFingerprint(filePath string, config *TokenFilterConfig, lang *LanguageConfig) error {
+	b, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	fp, err := tokenFilterFingerprint(config, lang)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(b)) != fp {
+		return errors.New("[token_filter], [language] or stopwords changed after supervised. run supervised again")
+	}
+	return nil
+}
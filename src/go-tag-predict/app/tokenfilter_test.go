@@ -0,0 +1,87 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+func ExampleTokenFilterChain() {
+	dir, _ := ioutil.TempDir("", "tokenfilter")
+	defer os.RemoveAll(dir)
+	ja := filepath.Join(dir, "ja.txt")
+	en := filepath.Join(dir, "en.txt")
+	ioutil.WriteFile(ja, []byte("# 日本語\nの\nです\n"), 0600)
+	ioutil.WriteFile(en, []byte("The\nof\n"), 0600)
+
+	tokens := []string{"Go", "の", "、", "ＧＯ", "１２３", "1,000", "3.14", "v1", "https://golang.org/", "the", "「", "です", "a", "tooooooooolong", " "}
+	for _, config := range []*TokenFilterConfig{
+		{},
+		{NFKC: true, Lowercase: true},
+		{NFKC: true, Lowercase: true, RemovePunct: true, CollapseNumbers: true, CollapseURLs: true},
+		{NFKC: true, Lowercase: true, RemovePunct: true, CollapseNumbers: true, CollapseURLs: true, Stopwords: map[string]string{"ja": ja, "en": en}, MinLength: 2, MaxLength: 10},
+	} {
+		chain, err := NewTokenFilterChain(config)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Println(chain.Apply(tokens, ""))
+	}
+
+	// ストップワードは言語ごとに使い分ける
+	chain, _ := NewTokenFilterChain(&TokenFilterConfig{Lowercase: true, Stopwords: map[string]string{"ja": ja, "en": en}})
+	for _, lang := range []string{"ja", "en", "zh", ""} {
+		fmt.Println(lang, chain.Apply([]string{"The", "の", "です", "go"}, lang))
+	}
+
+	_, err := NewTokenFilterChain(&TokenFilterConfig{Stopwords: map[string]string{"ja": filepath.Join(dir, "none.txt")}})
+	fmt.Println(os.IsNotExist(errors.Cause(err)))
+
+	// 学習時と分類時で設定やストップワードが違う場合はエラー
+	config := &TokenFilterConfig{NFKC: true, Stopwords: map[string]string{"ja": ja}}
+	fpPath := filepath.Join(dir, "token_filter.txt")
+	fmt.Println(checkTokenFilterFingerprint(fpPath, config, nil))
+	fmt.Println(saveTokenFilterFingerprint(fpPath, config, nil))
+	fmt.Println(checkTokenFilterFingerprint(fpPath, config, &LanguageConfig{}))
+	fmt.Println(checkTokenFilterFingerprint(fpPath, &TokenFilterConfig{NFKC: true, Lowercase: true, Stopwords: map[string]string{"ja": ja}}, nil))
+	fmt.Println(checkTokenFilterFingerprint(fpPath, config, &LanguageConfig{Enabled: true}))
+	ioutil.WriteFile(ja, []byte("の\n"), 0600)
+	fmt.Println(checkTokenFilterFingerprint(fpPath, config, nil))
+
+	// Output:
+	// [Go の 、 ＧＯ １２３ 1,000 3.14 v1 https://golang.org/ the 「 です a tooooooooolong]
+	// [go の 、 go 123 1,000 3.14 v1 https://golang.org/ the 「 です a tooooooooolong]
+	// [go の go <num> <num> <num> v1 <url> the です a tooooooooolong]
+	// [go go <num> <num> <num> v1 <url>]
+	// ja [the go]
+	// en [の です go]
+	// zh [the の です go]
+	//  [go]
+	// true
+	// <nil>
+	// <nil>
+	// <nil>
+	// [token_filter], [language] or stopwords changed after supervised. run supervised again
+	// [token_filter], [language] or stopwords changed after supervised. run supervised again
+	// [token_filter], [language] or stopwords changed after supervised. run supervised again
+}
+
+// URLは分かち書きで記号ごとに分割されるので、分かち書きの前に置き換える
+func ExampleTokenize_collapseURLs() {
+	text := "Go 1.18: see https://go.dev/doc/go1.18?x=1#generics, or www.golang.org/ref/spec."
+	for _, collapse := range []bool{false, true} {
+		config := NewConfig()
+		config.Tokenizer.Name = "unicode"
+		config.TokenFilter = &TokenFilterConfig{Lowercase: true, CollapseURLs: collapse}
+		config.tokenFilter, _ = NewTokenFilterChain(config.TokenFilter)
+		fmt.Println(Tokenize(context.Background(), config, text))
+	}
+	// Output:
+	// [go 1 18 see https go dev doc go1 18 x 1 generics or www golang org ref spec] <nil>
+	// [go 1 18 see <url> or <url>] <nil>
+}
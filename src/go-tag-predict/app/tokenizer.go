@@ -2,64 +2,235 @@ package app
 
 import (
 	"context"
-	"go-tag-predict/lambda"
-	"go-tag-predict/osutil"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
-	mecab "github.com/shogo82148/go-mecab"
 )
 
-// Tokenize : テキストを単語で分割する
-func Tokenize(ctx context.Context, config *Config, s string) ([]string, error) {
-	if config.Tokenizer == "mecab" {
-		return tokenizeMecab(ctx, config.Mecab, s)
-	} else if config.Tokenizer == "jumanpp" {
-		return tokenizeJumanpp(ctx, config.Jumanpp, s)
+// Tokenizer : テキストを単語で分割する
+// 複数Goルーチンから同時に呼び出すので、goroutine-safeにする
+// Closeで辞書などのリソースを解放する
+type Tokenizer interface {
+	Tokenize(ctx context.Context, s string) ([]string, error)
+	Close() error
+}
+
+// ErrTokenizerClosed : Close済みのTokenizerを使おうとした
+var ErrTokenizerClosed = errors.New("tokenizer closed")
+
+// TokenizerFactory : 設定からTokenizerを生成する
+type TokenizerFactory func(config *Config) (Tokenizer, error)
+
+var (
+	tokenizerMutex     sync.RWMutex
+	tokenizerFactories = map[string]TokenizerFactory{}
+)
+
+// defaultTokenizerName : tokenizerを指定しない場合に使うTokenizer
+// mecabを登録するビルドではmecab、-tags nomecabのビルドではkagome
+var defaultTokenizerName = "kagome"
+
+// RegisterTokenizer : tokenizerで指定する名前で、Tokenizerの生成方法を登録する
+// 同じ名前の生成方法は上書きする
+func RegisterTokenizer(name string, f TokenizerFactory) {
+	defer tokenizerMutex.Unlock()
+	tokenizerMutex.Lock()
+	tokenizerFactories[name] = f
+}
+
+// Tokenizers : 登録されているTokenizerの名前
+func Tokenizers() []string {
+	defer tokenizerMutex.RUnlock()
+	tokenizerMutex.RLock()
+	res := make([]string, 0, len(tokenizerFactories))
+	for name := range tokenizerFactories {
+		res = append(res, name)
 	}
-	panic("bad tokenizer (mecab or jumanpp)")
+	sort.Strings(res)
+	return res
 }
-func tokenizeMecab(ctx context.Context, config *MecabConfig, s string) ([]string, error) {
-	tagger, err := mecab.New(map[string]string{
-		"output-format-type": "wakati",
-		"dicdir":             config.DictDirPath,
-	})
-	if err != nil {
-		return nil, errors.WithStack(err)
+
+// NewTokenizer : 設定のtokenizerの名前でTokenizerを生成する
+func NewTokenizer(config *Config) (Tokenizer, error) {
+	return newNamedTokenizer(config, config.Tokenizer.Name)
+}
+
+func newNamedTokenizer(config *Config, name string) (Tokenizer, error) {
+	tokenizerMutex.RLock()
+	f, ok := tokenizerFactories[name]
+	tokenizerMutex.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("bad tokenizer: %q (%s)", name, strings.Join(Tokenizers(), ", "))
 	}
-	defer tagger.Destroy()
+	return f(config)
+}
 
-	// 入力データが大きすぎると、Mecabのエラー「too long sentence.」が発生する
-	if len(s) > 1024*256 {
-		s = s[:1024*256]
+// openTokenizers : tokenizerと、[language]で言語ごとに指定したTokenizerを生成する
+// 同じ名前のTokenizerは、言語が違っても1つを共有する
+func (c *Config) openTokenizers() error {
+	names := []string{c.Tokenizer.Name}
+	if c.Language.Enabled {
+		for _, name := range c.Language.Tokenizers {
+			names = append(names, name)
+		}
 	}
-	res, err := tagger.Parse(s)
-	if err != nil {
-		return nil, errors.WithStack(err)
-	}
-	lines := strings.Split(res, " ")
-	lines = lambda.MapString(lines, func(s string) string {
-		return strings.TrimSpace(s)
-	})
-	lines = lambda.FilterString(lines, func(s string) bool {
-		return s != ""
-	})
-	return lines, nil
+	c.tokenizers = make(map[string]Tokenizer, len(names))
+	for _, name := range names {
+		if _, ok := c.tokenizers[name]; ok {
+			continue
+		}
+		t, err := newNamedTokenizer(c, name)
+		if err != nil {
+			c.Close()
+			return err
+		}
+		c.tokenizers[name] = t
+	}
+	return nil
 }
-func tokenizeJumanpp(ctx context.Context, config *JumanppConfig, s string) ([]string, error) {
-	lines, err := osutil.ExecuteCommand(ctx, config.Command, config.Args, s)
+
+// Tokenize : テキストを単語で分割して、[token_filter]の設定でトークンを変換/除外する
+// TokenizerはLoadConfigで生成したものを使う (LoadConfigを使わない場合は、呼び出すたびに生成する)
+func Tokenize(ctx context.Context, config *Config, s string) ([]string, error) {
+	tokens, _, err := TokenizeLanguage(ctx, config, s)
+	return tokens, err
+}
+
+// TokenizeLanguage : [language]が有効な場合は、テキストの言語を判定して、その言語のtokenizerで分かち書きする
+// 判定した言語も返す (無効な場合は空文字)。featureの場合は、トークンの最後に<lang:ja>のような言語のトークンを加える
+func TokenizeLanguage(ctx context.Context, config *Config, s string) ([]string, string, error) {
+	name, lang := config.Tokenizer.Name, ""
+	if config.Language != nil && config.Language.Enabled {
+		if lang = DetectLanguage(s); lang == "" {
+			lang = config.Language.Default
+		}
+		if n, ok := config.Language.Tokenizers[lang]; ok {
+			name = n
+		}
+	}
+	t := config.tokenizers[name]
+	if t == nil {
+		var err error
+		if t, err = newNamedTokenizer(config, name); err != nil {
+			return nil, lang, err
+		}
+		defer t.Close()
+	}
+	if config.tokenFilter != nil {
+		s = config.tokenFilter.ReplaceURLs(s)
+	}
+	tokens, err := t.Tokenize(ctx, s)
 	if err != nil {
-		return nil, err
+		return nil, lang, err
+	}
+	if config.tokenFilter != nil {
+		// 言語を判定しない場合は、既定の言語のストップワードを使う
+		stopwordsLang := lang
+		if stopwordsLang == "" && config.Language != nil {
+			stopwordsLang = config.Language.Default
+		}
+		tokens = config.tokenFilter.Apply(tokens, stopwordsLang)
 	}
-	lines = lambda.MapString(lines, func(s string) string {
-		ar := strings.SplitN(string(s), config.TokenSeparator, 2)
+	if lang != "" && config.Language.Feature {
+		tokens = append(tokens, languageToken(lang))
+	}
+	return tokens, lang, nil
+}
+
+// parseMecabOutput : Mecabの出力(1行が「表層形<TAB>品詞,品詞細分類1,品詞細分類2,品詞細分類3,活用型,活用形,原形,...」)からトークンを取り出す
+// posを指定した場合はその品詞の単語だけを残し、base_formの場合は原形を使う (原形がない未知語などは表層形のまま)
+func parseMecabOutput(res string, config *MecabConfig) []string {
+	tokens := make([]string, 0, 1024)
+	for _, line := range strings.Split(res, "\n") {
+		ar := strings.SplitN(line, "\t", 2)
 		if len(ar) != 2 {
-			return ""
-		}
-		return ar[0]
-	})
-	lines = lambda.FilterString(lines, func(s string) bool {
-		return s != ""
-	})
-	return lines, nil
+			continue // EOS
+		}
+		surface := strings.TrimSpace(ar[0])
+		features := strings.Split(ar[1], ",")
+		if surface == "" || !matchPOS(joinPOS(features[:minInt(4, len(features))]), config.POS) {
+			continue
+		}
+		tokens = append(tokens, baseForm(surface, features, config.BaseForm, config.GetBaseFormIndex()))
+	}
+	return tokens
+}
+
+// GetBaseFormIndex : 素性の中の原形の位置 (指定しない場合はIPA辞書の6)
+func (c *MecabConfig) GetBaseFormIndex() int {
+	if c.BaseFormIndex <= 0 {
+		return 6
+	}
+	return c.BaseFormIndex
+}
+
+// parseJumanppLines : Juman++の出力(1行が「表層形 読み 原形 品詞 品詞ID 品詞細分類 ...」)からトークンを取り出す
+// posを指定した場合はその品詞の単語だけを残し、base_formの場合は原形を使う
+// EOSと、@で始まる曖昧性のある候補の行は無視する
+func parseJumanppLines(lines []string, config *JumanppConfig) []string {
+	tokens := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if strings.HasPrefix(line, "@ ") {
+			continue
+		}
+		ar := strings.Split(line, config.TokenSeparator)
+		if len(ar) < 2 || ar[0] == "" {
+			continue // EOS
+		}
+		pos := make([]string, 0, 2)
+		if len(ar) > 3 {
+			pos = append(pos, ar[3])
+		}
+		if len(ar) > 5 {
+			pos = append(pos, ar[5])
+		}
+		if !matchPOS(joinPOS(pos), config.POS) {
+			continue
+		}
+		tokens = append(tokens, baseForm(ar[0], ar, config.BaseForm, 2))
+	}
+	return tokens
+}
+
+// joinPOS : 品詞と品詞細分類を","でつなぐ ("*"以降は除く)
+func joinPOS(pos []string) string {
+	res := make([]string, 0, len(pos))
+	for _, p := range pos {
+		if p == "*" || p == "" {
+			break
+		}
+		res = append(res, p)
+	}
+	return strings.Join(res, ",")
+}
+
+// matchPOS : 品詞がclassesのどれかに当てはまるか (classesが空の場合は全て当てはまる)
+// "名詞"は"名詞,一般"にも"名詞,固有名詞,人名"にも当てはまる
+func matchPOS(pos string, classes []string) bool {
+	if len(classes) == 0 {
+		return true
+	}
+	for _, c := range classes {
+		if pos == c || strings.HasPrefix(pos, c+",") {
+			return true
+		}
+	}
+	return false
+}
+
+// baseForm : useBaseFormの場合は、features[i]の原形を返す
+func baseForm(surface string, features []string, useBaseForm bool, i int) string {
+	if !useBaseForm || i >= len(features) || features[i] == "*" || features[i] == "" {
+		return surface
+	}
+	return features[i]
+}
+
+func minInt(i0 int, i1 int) int {
+	if i0 < i1 {
+		return i0
+	}
+	return i1
 }
@@ -0,0 +1,205 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"go-tag-predict/lambda"
+	"go-tag-predict/osutil"
+	"io"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	RegisterTokenizer("external", func(config *Config) (Tokenizer, error) {
+		if config.Tokenizer.External == nil || config.Tokenizer.External.Command == "" {
+			return nil, errors.New("external tokenizer command is required. set [tokenizer.external] command")
+		}
+		return newExternalTokenizer(config.Tokenizer.External)
+	})
+}
+
+// externalTokenizer : [tokenizer.external]の外部コマンドで分かち書きする
+//
+// residentの場合は、Juman++と同じようにプロセスを常駐させて、1文書分の出力を読み取るまでを1リクエストとする
+// oneshotの場合は、文書ごとにコマンドを実行して標準出力を全て読み取る
+type externalTokenizer struct {
+	config ExternalTokenizerConfig
+	// pool : residentの場合だけ使う
+	pool *osutil.ProcessPool
+}
+
+// externalToken : 外部コマンドが出力した1単語
+type externalToken struct {
+	Surface string `json:"surface"`
+	Lemma   string `json:"lemma"`
+	POS     string `json:"pos"`
+}
+
+// newExternalTokenizer : コンストラクタ
+// input, output, modeを省略した場合は、line, wakati, residentを使う
+func newExternalTokenizer(config *ExternalTokenizerConfig) (*externalTokenizer, error) {
+	t := &externalTokenizer{config: *config}
+	c := &t.config
+	switch c.Input {
+	case "":
+		c.Input = ExternalInputLine
+	case ExternalInputLine, ExternalInputNUL:
+	default:
+		return nil, errors.Errorf("bad tokenizer.external input: %q (line or nul)", c.Input)
+	}
+	switch c.Output {
+	case "":
+		c.Output = ExternalOutputWakati
+	case ExternalOutputWakati, ExternalOutputToken, ExternalOutputJSON:
+	default:
+		return nil, errors.Errorf("bad tokenizer.external output: %q (wakati, token or json)", c.Output)
+	}
+	switch c.Mode {
+	case "", ExternalModeResident:
+		c.Mode = ExternalModeResident
+		t.pool = osutil.NewProcessPool(context.Background(), c.Command, c.Args,
+			c.GetProcessCount(), time.Duration(c.TimeoutSeconds)*time.Second)
+	case ExternalModeOneshot:
+	default:
+		return nil, errors.Errorf("bad tokenizer.external mode: %q (resident or oneshot)", c.Mode)
+	}
+	return t, nil
+}
+
+// GetProcessCount : residentで常駐させるプロセス数 (指定しない場合はCPU数)
+func (c *ExternalTokenizerConfig) GetProcessCount() int {
+	if c.ProcessCount <= 0 {
+		return runtime.NumCPU()
+	}
+	return c.ProcessCount
+}
+
+func (t *externalTokenizer) Tokenize(ctx context.Context, s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return []string{}, nil
+	}
+	input := t.frame(s)
+	var tokens []externalToken
+	if t.pool == nil {
+		if t.config.TimeoutSeconds > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(t.config.TimeoutSeconds)*time.Second)
+			defer cancel()
+		}
+		// ExecuteCommandは最後に改行を送るので、入力の改行は取り除く
+		lines, err := osutil.ExecuteCommand(ctx, t.config.Command, t.config.Args, strings.TrimSuffix(input, "\n"))
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		// 文ごとに改行やEOSを出力するコマンドもあるので、空行を除いた全ての出力を1文書分とする
+		lines = lambda.FilterString(lines, func(line string) bool {
+			return strings.TrimSpace(line) != ""
+		})
+		r := bufio.NewReader(strings.NewReader(strings.Join(lines, "\n") + "\n"))
+		for {
+			ts, err := readExternalTokens(r, t.config.Output)
+			tokens = append(tokens, ts...)
+			if errors.Cause(err) == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		err := t.pool.Do(ctx, input, func(r *bufio.Reader) error {
+			var err error
+			tokens, err = readExternalTokens(r, t.config.Output)
+			return err
+		})
+		if errors.Cause(err) == osutil.ErrPoolClosed {
+			return nil, errors.WithStack(ErrTokenizerClosed)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if token.Surface == "" || !matchPOS(token.POS, t.config.POS) {
+			continue
+		}
+		if t.config.BaseForm && token.Lemma != "" && token.Lemma != "*" {
+			res = append(res, token.Lemma)
+		} else {
+			res = append(res, token.Surface)
+		}
+	}
+	return res, nil
+}
+
+func (t *externalTokenizer) Close() error {
+	if t.pool == nil {
+		return nil
+	}
+	return t.pool.Close()
+}
+
+// frame : 1文書を、inputの区切り方で外部コマンドへ送る文字列にする
+func (t *externalTokenizer) frame(s string) string {
+	if t.config.Input == ExternalInputNUL {
+		return strings.Replace(s, "\x00", "", -1) + "\x00"
+	}
+	return strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(s) + "\n"
+}
+
+// readExternalTokens : outputの形式で、1文書分の出力を読み取る
+func readExternalTokens(r *bufio.Reader, output string) ([]externalToken, error) {
+	switch output {
+	case ExternalOutputToken:
+		tokens := make([]externalToken, 0, 1024)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return tokens, errors.WithStack(err)
+			}
+			line = strings.TrimRight(line, "\r\n")
+			if line == "" || line == "EOS" {
+				return tokens, nil
+			}
+			ar := strings.Split(line, "\t")
+			token := externalToken{Surface: ar[0]}
+			if len(ar) > 1 {
+				token.POS = ar[1]
+			}
+			if len(ar) > 2 {
+				token.Lemma = ar[2]
+			}
+			tokens = append(tokens, token)
+		}
+	case ExternalOutputJSON:
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		var tokens []externalToken
+		if err := json.Unmarshal([]byte(line), &tokens); err != nil {
+			return nil, errors.Wrap(err, "bad tokenizer.external json output")
+		}
+		return tokens, nil
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fields := strings.Fields(line)
+	tokens := make([]externalToken, 0, len(fields))
+	for _, field := range fields {
+		tokens = append(tokens, externalToken{Surface: field})
+	}
+	return tokens, nil
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+)
+
+// fakeTokenOutput : 1行の文書を、1行1単語(表層形<TAB>品詞<TAB>原形)で出力する
+const fakeTokenOutput = `while read l; do
+  for w in $l; do
+    case "$w" in
+      も|の) printf '%s\t助詞,係助詞\t%s\n' "$w" "$w";;
+      走っ) printf '%s\t動詞,自立\t走る\n' "$w";;
+      *) printf '%s\t名詞,一般\t*\n' "$w";;
+    esac
+  done
+  echo EOS
+done`
+
+// fakeJSONOutput : NUL文字で区切った文書を、1行のJSONで出力する (bashのread -dを使う)
+const fakeJSONOutput = `while IFS= read -r -d '' l; do
+  printf '['
+  sep=''
+  for w in $l; do printf '%s{"surface":"%s","lemma":"%s","pos":"X"}' "$sep" "$w" "$w"; sep=','; done
+  echo ']'
+done`
+
+func ExampleTokenizerConfig_UnmarshalTOML() {
+	for _, s := range []string{
+		`tokenizer = "kagome"`,
+		"[tokenizer]\nname = \"external\"\n[tokenizer.external]\ncommand = \"sudachipy\"\nargs = [\"-m\", \"C\"]\noutput = \"token\"\n",
+		`tokenizer = 1`,
+	} {
+		config := NewConfig()
+		_, err := toml.Decode(s, config)
+		fmt.Println(config.Tokenizer.Name, config.Tokenizer.External != nil, err != nil)
+		if config.Tokenizer.External != nil {
+			fmt.Println(config.Tokenizer.External.Command, config.Tokenizer.External.Args, config.Tokenizer.External.Output)
+		}
+	}
+	// Output:
+	// kagome false false
+	// external true false
+	// sudachipy [-m C] token
+	//  false true
+}
+
+func Example_externalTokenizer() {
+	ctx := context.Background()
+	for _, c := range []*ExternalTokenizerConfig{
+		{Command: "cat", ProcessCount: 1},
+		{Command: "sh", Args: []string{"-c", fakeTokenOutput}, Output: ExternalOutputToken, ProcessCount: 1},
+		{Command: "sh", Args: []string{"-c", fakeTokenOutput}, Output: ExternalOutputToken, Mode: ExternalModeOneshot, BaseForm: true, POS: []string{"名詞", "動詞"}},
+		{Command: "bash", Args: []string{"-c", fakeJSONOutput}, Input: ExternalInputNUL, Output: ExternalOutputJSON, ProcessCount: 1},
+		{Command: "bash", Args: []string{"-c", fakeJSONOutput}, Input: ExternalInputNUL, Output: ExternalOutputJSON, Mode: ExternalModeOneshot},
+	} {
+		t, err := newExternalTokenizer(c)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Println(t.Tokenize(ctx, "すもも も\nもも も 走っ た"))
+		fmt.Println(t.Tokenize(ctx, "もも の うち"))
+		fmt.Println(t.Tokenize(ctx, "\n"))
+		t.Close()
+	}
+
+	_, err := newExternalTokenizer(&ExternalTokenizerConfig{Command: "cat", Output: "xml"})
+	fmt.Println(err)
+	// Output:
+	// [すもも も もも も 走っ た] <nil>
+	// [もも の うち] <nil>
+	// [] <nil>
+	// [すもも も もも も 走っ た] <nil>
+	// [もも の うち] <nil>
+	// [] <nil>
+	// [すもも もも 走る た] <nil>
+	// [もも うち] <nil>
+	// [] <nil>
+	// [すもも も もも も 走っ た] <nil>
+	// [もも の うち] <nil>
+	// [] <nil>
+	// [すもも も もも も 走っ た] <nil>
+	// [もも の うち] <nil>
+	// [] <nil>
+	// bad tokenizer.external output: "xml" (wakati, token or json)
+}
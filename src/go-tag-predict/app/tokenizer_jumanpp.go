@@ -0,0 +1,108 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"go-tag-predict/osutil"
+	"runtime"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// jumanppMaxLineBytes : Juman++へ1行で送る最大バイト数
+// 長すぎる行はJuman++が解析せずにEOSを返さないことがあるので、分割して送る
+const jumanppMaxLineBytes = 4096
+
+func init() {
+	RegisterTokenizer("jumanpp", func(config *Config) (Tokenizer, error) {
+		if config.Jumanpp == nil || config.Jumanpp.Command == "" {
+			return nil, errors.New("jumanpp command is required. set [jumanpp] command")
+		}
+		return newJumanppTokenizer(config.Jumanpp), nil
+	})
+}
+
+// jumanppTokenizer : Juman++を常駐させて分かち書きする
+//
+// 起動と辞書の読み込みに時間がかかるので、process_count個のプロセスを使い回す
+// 1文書を1行ずつ送り、送った行数分のEOSを受け取るまでを1リクエストとする
+// timeout_secondsを超えて応答しないプロセスは、強制終了して次の利用時に起動し直す
+type jumanppTokenizer struct {
+	config *JumanppConfig
+	pool   *osutil.ProcessPool
+}
+
+func newJumanppTokenizer(config *JumanppConfig) *jumanppTokenizer {
+	return &jumanppTokenizer{
+		config: config,
+		pool: osutil.NewProcessPool(context.Background(), config.Command, config.Args,
+			config.GetProcessCount(), time.Duration(config.TimeoutSeconds)*time.Second),
+	}
+}
+
+func (t *jumanppTokenizer) Tokenize(ctx context.Context, s string) ([]string, error) {
+	input := jumanppInputLines(s)
+	if len(input) == 0 {
+		return []string{}, nil
+	}
+	var lines []string
+	err := t.pool.Do(ctx, strings.Join(input, "\n")+"\n", func(r *bufio.Reader) error {
+		lines = make([]string, 0, len(s)/3)
+		for eos := 0; eos < len(input); {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			line = strings.TrimRight(line, "\r\n")
+			if line == "EOS" {
+				eos++
+				continue
+			}
+			lines = append(lines, line)
+		}
+		return nil
+	})
+	if errors.Cause(err) == osutil.ErrPoolClosed {
+		return nil, errors.WithStack(ErrTokenizerClosed)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseJumanppLines(lines, t.config), nil
+}
+
+func (t *jumanppTokenizer) Close() error {
+	return t.pool.Close()
+}
+
+// jumanppInputLines : Juman++へ送る行に分ける
+// 空行はEOSだけが返ってくるとは限らないので送らない。長すぎる行は文字の境界で分割する
+func jumanppInputLines(s string) []string {
+	res := []string{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		for len(line) > jumanppMaxLineBytes {
+			i := jumanppMaxLineBytes
+			for i > 0 && !utf8.RuneStart(line[i]) {
+				i--
+			}
+			res = append(res, line[:i])
+			line = strings.TrimSpace(line[i:])
+		}
+		if line != "" {
+			res = append(res, line)
+		}
+	}
+	return res
+}
+
+// GetProcessCount : 常駐させるJuman++のプロセス数 (指定しない場合はCPU数)
+func (c *JumanppConfig) GetProcessCount() int {
+	if c.ProcessCount <= 0 {
+		return runtime.NumCPU()
+	}
+	return c.ProcessCount
+}
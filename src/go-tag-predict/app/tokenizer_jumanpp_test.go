@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"go-tag-predict/fileutil"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// fakeJumanpp : 1行ごとに、空白で区切った単語を名詞として出力してEOSを返す
+// "hang"を含む行は応答しない
+const fakeJumanpp = `while read l; do
+  case "$l" in *hang*) sleep 10;; esac
+  for w in $l; do echo "$w $w $w 名詞 6 普通名詞 1 * 0 * 0 NIL"; done
+  echo EOS
+done`
+
+func Example_jumanppTokenizer() {
+	ctx := context.Background()
+	t := newJumanppTokenizer(&JumanppConfig{
+		Command:        "sh",
+		Args:           []string{"-c", fakeJumanpp},
+		TokenSeparator: " ",
+		ProcessCount:   1,
+		TimeoutSeconds: 1,
+	})
+
+	ar, err := t.Tokenize(ctx, "すもも も\n\nもも も もも\n")
+	fmt.Println(ar, err)
+	ar, err = t.Tokenize(ctx, " \n")
+	fmt.Println(ar, err)
+
+	// 応答しないプロセスはタイムアウトで終了させて、次の文書は起動し直したプロセスで処理する
+	_, err = t.Tokenize(ctx, "hang")
+	fmt.Println(strings.HasPrefix(errors.Cause(err).Error(), "process timeout"))
+	ar, err = t.Tokenize(ctx, "もも の うち")
+	fmt.Println(ar, err)
+
+	fmt.Println(t.Close())
+	_, err = t.Tokenize(ctx, "うち")
+	fmt.Println(errors.Cause(err) == ErrTokenizerClosed)
+	// Output:
+	// [すもも も もも も もも] <nil>
+	// [] <nil>
+	// true
+	// [もも の うち] <nil>
+	// <nil>
+	// true
+}
+
+func Example_jumanppInputLines() {
+	long := strings.Repeat("あ", jumanppMaxLineBytes/3+1)
+	for _, line := range jumanppInputLines(" すもも \n\n" + long) {
+		fmt.Println(len(line))
+	}
+	// Output:
+	// 9
+	// 4095
+	// 3
+}
+
+func ExampleTokenizeJumanpp() {
+	configPath := path.Join(fileutil.GetGopath()[0], "go-tag-predict.toml")
+	config, err := LoadConfig(configPath)
+
+	t := newJumanppTokenizer(config.Jumanpp)
+	defer t.Close()
+	ar, err := t.Tokenize(context.Background(), "")
+	fmt.Println(err)
+	fmt.Println(len(ar))
+
+	ar, err = t.Tokenize(context.Background(), "あのイーハトーヴォのすきとおった風、夏でも底に冷たさをもつ青いそら、\nうつくしい森で飾られたモリーオ市、郊外のぎらぎらひかる草の波。")
+	fmt.Println(err)
+	for _, s := range ar {
+		fmt.Println(s)
+	}
+	// Output:
+	// <nil>
+	// 0
+	// <nil>
+	// あの
+	// イーハトーヴォ
+	// の
+	// すきとおった
+	// 風
+	// 、
+	// 夏
+	// でも
+	// 底
+	// に
+	// 冷た
+	// さ
+	// を
+	// もつ
+	// 青い
+	// そら
+	// 、
+	// うつくしい
+	// 森
+	// で
+	// 飾ら
+	// れた
+	// モリーオ
+	// 市
+	// 、
+	// 郊外
+	// の
+	// ぎらぎら
+	// ひかる
+	// 草
+	// の
+	// 波
+	// 。
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"context"
+
+	"github.com/ikawaha/kagome/tokenizer"
+)
+
+func init() {
+	RegisterTokenizer("kagome", func(config *Config) (Tokenizer, error) {
+		c := config.Kagome
+		if c == nil {
+			c = &KagomeConfig{}
+		}
+		return &kagomeTokenizer{config: c, t: tokenizer.New()}, nil
+	})
+}
+
+// kagomeTokenizer : Go製の形態素解析器kagomeで分かち書きする
+// IPA辞書を埋め込んでいるので、cgo, libmecab, 辞書のインストールが不要
+type kagomeTokenizer struct {
+	config *KagomeConfig
+	t      tokenizer.Tokenizer
+}
+
+func (t *kagomeTokenizer) Tokenize(ctx context.Context, s string) ([]string, error) {
+	tokens := make([]string, 0, 1024)
+	for _, token := range t.t.Tokenize(s) {
+		if token.Class == tokenizer.DUMMY { // BOS, EOS
+			continue
+		}
+		features := token.Features()
+		if token.Surface == "" || !matchPOS(joinPOS(features[:minInt(4, len(features))]), t.config.POS) {
+			continue
+		}
+		tokens = append(tokens, baseForm(token.Surface, features, t.config.BaseForm, 6))
+	}
+	return tokens, nil
+}
+
+func (t *kagomeTokenizer) Close() error {
+	return nil
+}
@@ -0,0 +1,174 @@
+//go:build !nomecab
+// +build !nomecab
+
+package app
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/pkg/errors"
+	mecab "github.com/shogo82148/go-mecab"
+)
+
+// MeCabはcgoとlibmecabが必要なので、-tags nomecabでビルドした場合は登録しない
+func init() {
+	defaultTokenizerName = "mecab"
+	RegisterTokenizer("mecab", func(config *Config) (Tokenizer, error) {
+		c := config.Mecab
+		if c == nil {
+			c = &MecabConfig{}
+		}
+		return newMecabTokenizer(c), nil
+	})
+}
+
+// mecabTokenizer : MeCabで分かち書きする
+//
+// 辞書(Model)は最初に使われた時に1度だけ読み込んで共有し、
+// taggerとlatticeの組をpool_size個までプールして使い回す
+type mecabTokenizer struct {
+	config *MecabConfig
+	slots  chan *mecabTagger
+	size   int
+
+	once  sync.Once
+	model mecab.Model
+	err   error
+
+	mutex  sync.Mutex
+	closed bool
+}
+
+type mecabTagger struct {
+	tagger  mecab.MeCab
+	lattice mecab.Lattice
+}
+
+func newMecabTokenizer(config *MecabConfig) *mecabTokenizer {
+	size := config.GetPoolSize()
+	t := &mecabTokenizer{config: config, slots: make(chan *mecabTagger, size), size: size}
+	for i := 0; i < size; i++ {
+		t.slots <- nil // 未生成
+	}
+	return t
+}
+
+// GetPoolSize : 使い回すtaggerの数 (指定しない場合はCPU数)
+func (c *MecabConfig) GetPoolSize() int {
+	if c.PoolSize <= 0 {
+		return runtime.NumCPU()
+	}
+	return c.PoolSize
+}
+
+func (t *mecabTokenizer) Tokenize(ctx context.Context, s string) ([]string, error) {
+	t.once.Do(func() {
+		t.model, t.err = mecab.NewModel(map[string]string{
+			"dicdir": t.config.DictDirPath,
+		})
+		t.err = errors.WithStack(t.err)
+	})
+	if t.err != nil {
+		return nil, t.err
+	}
+
+	var tg *mecabTagger
+	select {
+	case tg = <-t.slots:
+	case <-ctx.Done():
+		return nil, errors.WithStack(ctx.Err())
+	}
+	defer func() {
+		t.slots <- tg
+	}()
+	if tg == nil {
+		t.mutex.Lock()
+		closed := t.closed
+		t.mutex.Unlock()
+		if closed {
+			return nil, errors.WithStack(ErrTokenizerClosed)
+		}
+		var err error
+		if tg, err = t.newTagger(); err != nil {
+			return nil, err
+		}
+	}
+
+	tg.lattice.SetSentence(truncateMecabInput(s))
+	if err := tg.tagger.ParseLattice(tg.lattice); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return parseMecabOutput(tg.lattice.String(), t.config), nil
+}
+
+func (t *mecabTokenizer) newTagger() (*mecabTagger, error) {
+	tagger, err := t.model.NewMeCab()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	lattice, err := mecab.NewLattice()
+	if err != nil {
+		tagger.Destroy()
+		return nil, errors.WithStack(err)
+	}
+	return &mecabTagger{tagger: tagger, lattice: lattice}, nil
+}
+
+// Close : taggerと辞書を解放する
+// 処理中のリクエストがある場合は、その完了を待つ。閉じた後に使われた場合は、ErrTokenizerClosedを返す
+func (t *mecabTokenizer) Close() error {
+	t.mutex.Lock()
+	if t.closed {
+		t.mutex.Unlock()
+		return nil
+	}
+	t.closed = true
+	t.mutex.Unlock()
+
+	tgs := make([]*mecabTagger, 0, t.size)
+	for i := 0; i < t.size; i++ {
+		tgs = append(tgs, <-t.slots)
+	}
+	for _, tg := range tgs {
+		if tg != nil {
+			tg.lattice.Destroy()
+			tg.tagger.Destroy()
+		}
+		t.slots <- nil
+	}
+	// まだ辞書を読み込んでいない場合は、以降も読み込まないようにする
+	t.once.Do(func() {
+		t.err = errors.WithStack(ErrTokenizerClosed)
+	})
+	if t.err == nil {
+		t.model.Destroy()
+	}
+	return nil
+}
+
+// truncateMecabInput : 入力データが大きすぎると、Mecabのエラー「too long sentence.」が発生する
+func truncateMecabInput(s string) string {
+	if len(s) > 1024*256 {
+		return s[:1024*256]
+	}
+	return s
+}
+
+// tokenizeMecab : 呼び出すたびに辞書を読み込んで分かち書きする (プールを使わない場合の比較用)
+func tokenizeMecab(ctx context.Context, config *MecabConfig, s string) ([]string, error) {
+	tagger, err := mecab.New(map[string]string{
+		"dicdir": config.DictDirPath,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer tagger.Destroy()
+
+	res, err := tagger.Parse(truncateMecabInput(s))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return parseMecabOutput(res, config), nil
+}
@@ -0,0 +1,118 @@
+//go:build !nomecab
+// +build !nomecab
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"go-tag-predict/fileutil"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func ExampleTokenizeMecab() {
+	configPath := path.Join(fileutil.GetGopath()[0], "go-tag-predict.toml")
+	config, err := LoadConfig(configPath)
+
+	ar, err := tokenizeMecab(context.Background(), config.Mecab, "")
+	fmt.Println(err)
+	fmt.Println(len(ar))
+
+	ar, err = tokenizeMecab(context.Background(), config.Mecab, "あのイーハトーヴォのすきとおった風、夏でも底に冷たさをもつ青いそら、\nうつくしい森で飾られたモリーオ市、郊外のぎらぎらひかる草の波。")
+	fmt.Println(err)
+	for _, s := range ar {
+		fmt.Println(s)
+	}
+	// Output:
+	// <nil>
+	// 0
+	// <nil>
+	// あの
+	// イーハトーヴォ
+	// の
+	// すきとおっ
+	// た
+	// 風
+	// 、
+	// 夏
+	// で
+	// も
+	// 底
+	// に
+	// 冷た
+	// さ
+	// を
+	// もつ
+	// 青い
+	// そら
+	// 、
+	// うつくしい
+	// 森
+	// で
+	// 飾ら
+	// れ
+	// た
+	// モリーオ
+	// 市
+	// 、
+	// 郊外
+	// の
+	// ぎらぎら
+	// ひかる
+	// 草
+	// の
+	// 波
+	// 。
+}
+func Example_mecabTokenizer_Close() {
+	t := newMecabTokenizer(&MecabConfig{PoolSize: 2})
+	fmt.Println(t.Close())
+	fmt.Println(t.Close())
+	_, err := t.Tokenize(context.Background(), "すもももももももものうち")
+	fmt.Println(errors.Cause(err) == ErrTokenizerClosed)
+	// Output:
+	// <nil>
+	// <nil>
+	// true
+}
+
+// 呼び出すたびに辞書を読み込む場合と、辞書を共有してtaggerをプールする場合の比較
+//
+//	$ go test -run NONE -bench MecabTokenizer -cpu 8 ./app
+func BenchmarkMecabTokenizer(b *testing.B) {
+	configPath := path.Join(fileutil.GetGopath()[0], "go-tag-predict.toml")
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		b.Skip(err)
+	}
+	ctx := context.Background()
+	text := strings.Repeat("あのイーハトーヴォのすきとおった風、夏でも底に冷たさをもつ青いそら、\nうつくしい森で飾られたモリーオ市、郊外のぎらぎらひかる草の波。\n", 20)
+	if _, err := tokenizeMecab(ctx, config.Mecab, text); err != nil {
+		b.Skip(err)
+	}
+
+	b.Run("per-call", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := tokenizeMecab(ctx, config.Mecab, text); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+	b.Run("pool", func(b *testing.B) {
+		t := newMecabTokenizer(config.Mecab)
+		defer t.Close()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := t.Tokenize(ctx, text); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+}
@@ -3,112 +3,77 @@ package app
 import (
 	"context"
 	"fmt"
-	"go-tag-predict/fileutil"
-	"path"
+	"strings"
 )
 
-func ExampleTokenizeMecab() {
-	configPath := path.Join(fileutil.GetGopath()[0], "go-tag-predict.toml")
-	config, err := LoadConfig(configPath)
-
-	ar, err := tokenizeMecab(context.Background(), config.Mecab, "")
-	fmt.Println(err)
-	fmt.Println(len(ar))
-
-	ar, err = tokenizeMecab(context.Background(), config.Mecab, "あのイーハトーヴォのすきとおった風、夏でも底に冷たさをもつ青いそら、\nうつくしい森で飾られたモリーオ市、郊外のぎらぎらひかる草の波。")
-	fmt.Println(err)
-	for _, s := range ar {
-		fmt.Println(s)
+func Example_parseMecabOutput() {
+	res := "あの\t連体詞,*,*,*,*,*,あの,アノ,アノ\n" +
+		"すきとおっ\t動詞,自立,*,*,五段・ラ行,連用タ接続,すきとおる,スキトオッ,スキトーッ\n" +
+		"た\t助動詞,*,*,*,特殊・タ,基本形,た,タ,タ\n" +
+		"風\t名詞,一般,*,*,*,*,風,カゼ,カゼ\n" +
+		"、\t記号,読点,*,*,*,*,、,、,、\n" +
+		"モリーオ\t名詞,固有名詞,地域,一般,*,*,*\n" +
+		"青い\t形容詞,自立,*,*,形容詞・アウオ段,基本形,青い,アオイ,アオイ\n" +
+		"EOS\n"
+	for _, config := range []*MecabConfig{
+		{},
+		{BaseForm: true},
+		{BaseForm: true, POS: []string{"名詞", "動詞", "形容詞"}},
+		{POS: []string{"名詞,固有名詞"}},
+	} {
+		fmt.Println(parseMecabOutput(res, config))
 	}
 	// Output:
-	// <nil>
-	// 0
-	// <nil>
-	// あの
-	// イーハトーヴォ
-	// の
-	// すきとおっ
-	// た
-	// 風
-	// 、
-	// 夏
-	// で
-	// も
-	// 底
-	// に
-	// 冷た
-	// さ
-	// を
-	// もつ
-	// 青い
-	// そら
-	// 、
-	// うつくしい
-	// 森
-	// で
-	// 飾ら
-	// れ
-	// た
-	// モリーオ
-	// 市
-	// 、
-	// 郊外
-	// の
-	// ぎらぎら
-	// ひかる
-	// 草
-	// の
-	// 波
-	// 。
+	// [あの すきとおっ た 風 、 モリーオ 青い]
+	// [あの すきとおる た 風 、 モリーオ 青い]
+	// [すきとおる 風 モリーオ 青い]
+	// [モリーオ]
 }
-func ExampleTokenizeJumanpp() {
-	configPath := path.Join(fileutil.GetGopath()[0], "go-tag-predict.toml")
-	config, err := LoadConfig(configPath)
 
-	ar, err := tokenizeMecab(context.Background(), config.Mecab, "")
-	fmt.Println(err)
-	fmt.Println(len(ar))
+func Example_parseJumanppLines() {
+	lines := []string{
+		`あの あの あの 指示詞 7 連体詞形態指示詞 2 * 0 * 0 NIL`,
+		`すきとおった すきとおった すきとおる 動詞 2 * 0 子音動詞ラ行 10 タ形 10 "代表表記:透き通る/すきとおる"`,
+		`@ すきとおった すきとおった すきとおる 動詞 2 * 0 子音動詞ラ行 10 タ形 10 NIL`,
+		`風 かぜ 風 名詞 6 普通名詞 1 * 0 * 0 "代表表記:風/かぜ"`,
+		`、 、 、 特殊 1 読点 2 * 0 * 0 NIL`,
+		`モリーオ もりーお モリーオ 名詞 6 地名 4 * 0 * 0 NIL`,
+		`EOS`,
+	}
+	for _, config := range []*JumanppConfig{
+		{TokenSeparator: " "},
+		{TokenSeparator: " ", BaseForm: true},
+		{TokenSeparator: " ", BaseForm: true, POS: []string{"名詞", "動詞"}},
+		{TokenSeparator: " ", POS: []string{"名詞,地名"}},
+	} {
+		fmt.Println(parseJumanppLines(lines, config))
+	}
+	// Output:
+	// [あの すきとおった 風 、 モリーオ]
+	// [あの すきとおる 風 、 モリーオ]
+	// [すきとおる 風 モリーオ]
+	// [モリーオ]
+}
 
-	ar, err = tokenizeJumanpp(context.Background(), config.Jumanpp, "あのイーハトーヴォのすきとおった風、夏でも底に冷たさをもつ青いそら、\nうつくしい森で飾られたモリーオ市、郊外のぎらぎらひかる草の波。")
-	fmt.Println(err)
-	for _, s := range ar {
-		fmt.Println(s)
+func ExampleNewTokenizer() {
+	config := NewConfig()
+	for _, name := range []string{"kagome", "unknown"} {
+		config.Tokenizer.Name = name
+		t, err := NewTokenizer(config)
+		if err != nil {
+			fmt.Println(strings.Contains(err.Error(), `bad tokenizer: "unknown" (`))
+			continue
+		}
+		fmt.Println(t.Tokenize(context.Background(), "すもももももももものうち"))
 	}
+
+	config.Tokenizer.Name = "kagome"
+	config.Kagome = &KagomeConfig{BaseForm: true, POS: []string{"名詞,一般", "動詞"}}
+	fmt.Println(Tokenize(context.Background(), config, "すもももももももものうち"))
+	fmt.Println(Tokenize(context.Background(), config, "走った"))
 	// Output:
-	// <nil>
-	// 0
-	// <nil>
-	// あの
-	// イーハトーヴォ
-	// の
-	// すきとおった
-	// 風
-	// 、
-	// 夏
-	// でも
-	// 底
-	// に
-	// 冷た
-	// さ
-	// を
-	// もつ
-	// 青い
-	// そら
-	// 、
-	// うつくしい
-	// 森
-	// で
-	// 飾ら
-	// れた
-	// モリーオ
-	// 市
-	// 、
-	// 郊外
-	// の
-	// ぎらぎら
-	// ひかる
-	// 草
-	// の
-	// 波
-	// 。
+	// [すもも も もも も もも の うち] <nil>
+	// true
+	// [すもも もも もも] <nil>
+	// [走る] <nil>
 }
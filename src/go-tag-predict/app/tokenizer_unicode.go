@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"go-tag-predict/stemmer"
+	"strings"
+	"unicode"
+)
+
+func init() {
+	RegisterTokenizer("unicode", func(config *Config) (Tokenizer, error) {
+		c := config.Unicode
+		if c == nil {
+			c = &UnicodeConfig{}
+		}
+		return &unicodeTokenizer{config: c}, nil
+	})
+}
+
+// unicodeTokenizer : Unicodeの文字種で単語に分割する (英語など、空白で単語を区切る言語向け)
+// 文字と数字が続く部分を1単語とし、単語の中のアポストロフィ(don't)はつなげたままにする
+// 外部の辞書やコマンドを必要としない
+type unicodeTokenizer struct {
+	config *UnicodeConfig
+}
+
+func (t *unicodeTokenizer) Tokenize(ctx context.Context, s string) ([]string, error) {
+	words := segmentWords(s)
+	if !t.config.Stem {
+		return words, nil
+	}
+	for i, word := range words {
+		words[i] = stemWord(word)
+	}
+	return words, nil
+}
+
+func (t *unicodeTokenizer) Close() error {
+	return nil
+}
+
+// segmentWords : 文字(結合文字を含む)と数字の続く部分を単語として取り出す
+func segmentWords(s string) []string {
+	rs := []rune(s)
+	words := make([]string, 0, len(rs)/5)
+	start := -1
+	for i, r := range rs {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && isApostrophe(r) && i+1 < len(rs) && isWordRune(rs[i+1]) {
+			continue
+		}
+		if start >= 0 {
+			words = append(words, string(rs[start:i]))
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, string(rs[start:]))
+	}
+	return words
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '’'
+}
+
+// stemWord : 小文字にして所有格の's を取り除き、Porterのステミングで語幹にする
+func stemWord(word string) string {
+	word = strings.Replace(strings.ToLower(word), "’", "'", -1)
+	word = strings.TrimSuffix(word, "'s")
+	return stemmer.Porter(word)
+}
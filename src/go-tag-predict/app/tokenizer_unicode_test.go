@@ -0,0 +1,17 @@
+package app
+
+import (
+	"context"
+	"fmt"
+)
+
+func Example_unicodeTokenizer() {
+	s := "Don't panic! The Go team's generics (go1.18) are connecting café-goers, e.g. ünïcödé."
+	for _, config := range []*UnicodeConfig{{}, {Stem: true}} {
+		t := &unicodeTokenizer{config: config}
+		fmt.Println(t.Tokenize(context.Background(), s))
+	}
+	// Output:
+	// [Don't panic The Go team's generics go1 18 are connecting café goers e g ünïcödé] <nil>
+	// [don't panic the go team gener go1 18 ar connect café goer e g ünïcödé] <nil>
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"context"
+	"go-tag-predict/webservice/pinboard"
+
+	"github.com/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+// pinboardWriteback : 分類結果のタグを付けて、ページをPinboardに保存する
+type pinboardWriteback struct {
+	config *WritebackConfig
+	client *pinboard.Client
+	logger *zap.Logger
+}
+
+// newPinboardWriteback : [predict.writeback]が無効な場合はnilを返す
+func newPinboardWriteback(config *Config, logger *zap.Logger) (*pinboardWriteback, error) {
+	wc := config.Predict.Writeback
+	if wc == nil || !wc.Enabled {
+		return nil, nil
+	}
+	token := config.Pinboard.GetToken()
+	if token == "" && !wc.DryRun {
+		return nil, errors.New("pinboard token is required. set [pinboard] token or PINBOARD_TOKEN")
+	}
+	c := pinboard.NewClient(token)
+	c.BaseURL = config.Pinboard.APIURL
+	c.DryRun = wc.DryRun
+	return &pinboardWriteback{config: wc, client: c, logger: logger}, nil
+}
+
+// Write : posts/addで保存する
+// 既に保存済みのページは、replaceが無効な場合はスキップする
+func (w *pinboardWriteback) Write(ctx context.Context, r *PredictRecord) error {
+	tags := make([]string, 0, len(r.Tags)+1)
+	for _, t := range r.Tags {
+		tags = append(tags, t.Tag)
+	}
+	if w.config.MarkerTag != "" {
+		tags = append(tags, w.config.MarkerTag)
+	}
+	err := w.client.Add(ctx, &pinboard.AddParams{
+		URL:         r.URL,
+		Description: r.Title,
+		Tags:        tags,
+		Replace:     w.config.Replace,
+		Shared:      w.config.Shared,
+		ToRead:      w.config.ToRead,
+	})
+	if errors.Cause(err) == pinboard.ErrItemExists {
+		w.logger.Debug("writeback skip",
+			zap.String("url", r.URL),
+			zap.String("err", err.Error()))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	w.logger.Info("writeback",
+		zap.String("url", r.URL),
+		zap.Strings("tags", tags),
+		zap.Bool("dry_run", w.config.DryRun))
+	return nil
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"go.uber.org/zap"
+)
+
+func Example_pinboardWriteback() {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		fmt.Println(r.URL.Path, q.Get("url"), q.Get("description"), q.Get("tags"), q.Get("toread"), q.Get("shared"))
+		if q.Get("url") == "http://exists" {
+			fmt.Fprint(w, `<result code="item already exists" />`)
+			return
+		}
+		fmt.Fprint(w, `<result code="done" />`)
+	}))
+	defer ts.Close()
+
+	config := &Config{
+		Predict:  &PredictConfig{Writeback: &WritebackConfig{Enabled: true, MarkerTag: "auto:predicted", ToRead: true}},
+		Pinboard: &PinboardConfig{Token: "user:TOKEN", APIURL: ts.URL},
+	}
+	wb, err := newPinboardWriteback(config, zap.NewNop())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	wb.client.Interval = 0
+	tags := PredictResults{&PredictResult{Tag: "golang", Probability: 0.5}}
+	fmt.Println(wb.Write(context.Background(), &PredictRecord{URL: "http://1", Title: "title", Tags: tags}))
+	fmt.Println(wb.Write(context.Background(), &PredictRecord{URL: "http://exists", Title: "title", Tags: tags}))
+
+	config.Predict.Writeback.Enabled = false
+	wb, err = newPinboardWriteback(config, zap.NewNop())
+	fmt.Println(wb == nil, err)
+
+	// Output:
+	// /posts/add http://1 title golang auto:predicted yes no
+	// <nil>
+	// /posts/add http://exists title golang auto:predicted yes no
+	// <nil>
+	// true <nil>
+}
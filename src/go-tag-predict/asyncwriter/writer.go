@@ -3,6 +3,9 @@ package asyncwriter
 import (
 	"bufio"
 	"context"
+	"sync"
+
+	"github.com/pkg/errors"
 )
 
 // Writer : 複数Goルーチンから非同期出力可能なWriter
@@ -15,10 +18,15 @@ import (
 //   ...
 //   aw.Close()
 //   <-aw.Done()
+//   if err := aw.Err(); err != nil {
+//     ...
+//   }
 type Writer struct {
 	ch     chan string
 	done   chan struct{}
 	writer *bufio.Writer
+	mutex  sync.Mutex
+	err    error
 }
 
 // NewWriter : コンストラクタ
@@ -42,10 +50,14 @@ func (a *Writer) start(ctx context.Context) {
 				if !ok {
 					break FOR
 				}
-				a.writer.WriteString(s)
+				// エラーの後も、WriteStringが詰まらないように読み捨てる
+				if a.Err() == nil {
+					_, err := a.writer.WriteString(s)
+					a.setErr(err)
+				}
 			}
 		}
-		a.writer.Flush()
+		a.setErr(a.writer.Flush())
 		a.done <- struct{}{}
 	}()
 }
@@ -64,3 +76,19 @@ func (a *Writer) Close() {
 func (a *Writer) Done() chan struct{} {
 	return a.done
 }
+
+// Err : 最初に発生した書き込み/Flushのエラー (ディスクフルなど)
+// Doneの後に呼び出すと、全ての書き込みの結果を返す
+func (a *Writer) Err() error {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+	return a.err
+}
+
+func (a *Writer) setErr(err error) {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+	if a.err == nil && err != nil {
+		a.err = errors.WithStack(err)
+	}
+}
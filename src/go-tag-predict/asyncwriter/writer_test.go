@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -37,3 +38,22 @@ func ExampleWriter() {
 	// Output:
 	// 100
 }
+
+type failWriter struct{}
+
+func (w failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("no space left on device")
+}
+
+func ExampleWriter_Err() {
+	aw := NewWriter(context.Background(), bufio.NewWriterSize(failWriter{}, 16), 1)
+	for i := 0; i < 10; i++ {
+		aw.WriteString("0123456789\n")
+	}
+	aw.Close()
+	<-aw.Done()
+	fmt.Println(aw.Err())
+
+	// Output:
+	// no space left on device
+}
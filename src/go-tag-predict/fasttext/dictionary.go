@@ -0,0 +1,211 @@
+package fasttext
+
+import (
+	"strings"
+)
+
+const (
+	eos         = "</s>"
+	bow         = "<"
+	eow         = ">"
+	labelPrefix = "__label__"
+)
+
+const (
+	entryWord  = 0
+	entryLabel = 1
+)
+
+type entry struct {
+	word     string
+	count    int64
+	typ      int8
+	subwords []int32
+}
+
+// dictionary : fastTextの単語とラベルの辞書
+type dictionary struct {
+	args     *args
+	words    []*entry
+	word2int map[string]int32
+	nwords   int32
+	nlabels  int32
+	pruneidx map[int32]int32
+	// pruneidxSize : -1の場合は枝刈りなし
+	pruneidxSize int64
+}
+
+func readDictionary(br *binaryReader, a *args) *dictionary {
+	d := &dictionary{args: a}
+	size := br.int32()
+	d.nwords = br.int32()
+	d.nlabels = br.int32()
+	br.int64() // ntokens
+	d.pruneidxSize = br.int64()
+	if br.err != nil {
+		return nil
+	}
+	d.words = make([]*entry, 0, size)
+	d.word2int = make(map[string]int32, size)
+	for i := int32(0); i < size && br.err == nil; i++ {
+		e := &entry{}
+		e.word = br.cstring()
+		e.count = br.int64()
+		e.typ = br.int8()
+		d.word2int[e.word] = i
+		d.words = append(d.words, e)
+	}
+	d.pruneidx = make(map[int32]int32)
+	for i := int64(0); i < d.pruneidxSize && br.err == nil; i++ {
+		first := br.int32()
+		second := br.int32()
+		d.pruneidx[first] = second
+	}
+	d.initNgrams()
+	return d
+}
+
+func (d *dictionary) initNgrams() {
+	for i, e := range d.words {
+		e.subwords = []int32{int32(i)}
+		if e.word != eos {
+			e.subwords = d.computeSubwords(bow+e.word+eow, e.subwords)
+		}
+	}
+}
+
+// hash : fastTextと同じFNV-1a
+// ※バイトを符号付きとして扱う
+func hash(s string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(s); i++ {
+		h = h ^ uint32(int8(s[i]))
+		h = h * 16777619
+	}
+	return h
+}
+
+// computeSubwords : 文字n-gramのIDを追加する
+func (d *dictionary) computeSubwords(word string, ngrams []int32) []int32 {
+	if d.args.bucket <= 0 {
+		return ngrams
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i]&0xC0 == 0x80 {
+			continue
+		}
+		ngram := make([]byte, 0, 32)
+		for j, n := i, int32(1); j < len(word) && n <= d.args.maxn; n++ {
+			ngram = append(ngram, word[j])
+			j++
+			for j < len(word) && word[j]&0xC0 == 0x80 {
+				ngram = append(ngram, word[j])
+				j++
+			}
+			if n >= d.args.minn && !(n == 1 && (i == 0 || j == len(word))) {
+				h := int32(hash(string(ngram)) % uint32(d.args.bucket))
+				ngrams = d.pushHash(ngrams, h)
+			}
+		}
+	}
+	return ngrams
+}
+
+func (d *dictionary) pushHash(hashes []int32, id int32) []int32 {
+	if d.pruneidxSize == 0 || id < 0 {
+		return hashes
+	}
+	if d.pruneidxSize > 0 {
+		if i, ok := d.pruneidx[id]; ok {
+			id = i
+		} else {
+			return hashes
+		}
+	}
+	return append(hashes, d.nwords+id)
+}
+
+// fastTextの区切り文字
+func isSpace(r rune) bool {
+	switch r {
+	case ' ', '\n', '\r', '\t', '\v', '\f', 0:
+		return true
+	}
+	return false
+}
+
+// getLine : 1行分の入力をinput行列のIDに変換する
+func (d *dictionary) getLine(line string) []int32 {
+	tokens := strings.FieldsFunc(line, isSpace)
+	tokens = append(tokens, eos)
+
+	words := make([]int32, 0, len(tokens)*2)
+	hashes := make([]int32, 0, len(tokens))
+	for _, token := range tokens {
+		h := hash(token)
+		wid, ok := d.word2int[token]
+		typ := int8(entryWord)
+		if ok {
+			typ = d.words[wid].typ
+		} else if strings.HasPrefix(token, labelPrefix) {
+			typ = entryLabel
+		}
+		if typ == entryWord {
+			words = d.addSubwords(words, token, wid, ok)
+			hashes = append(hashes, int32(h))
+		}
+	}
+	return d.addWordNgrams(words, hashes, d.args.wordNgrams)
+}
+
+func (d *dictionary) addSubwords(line []int32, token string, wid int32, ok bool) []int32 {
+	if !ok {
+		if token != eos {
+			return d.computeSubwords(bow+token+eow, line)
+		}
+		return line
+	}
+	if d.args.maxn <= 0 {
+		return append(line, wid)
+	}
+	return append(line, d.words[wid].subwords...)
+}
+
+func (d *dictionary) addWordNgrams(line []int32, hashes []int32, n int32) []int32 {
+	if d.args.bucket <= 0 {
+		return line
+	}
+	for i := 0; i < len(hashes); i++ {
+		// int32 => uint64は符号拡張する
+		h := uint64(int64(hashes[i]))
+		for j := i + 1; j < len(hashes) && j < i+int(n); j++ {
+			h = h*116049371 + uint64(int64(hashes[j]))
+			line = d.pushHash(line, int32(h%uint64(d.args.bucket)))
+		}
+	}
+	return line
+}
+
+func (d *dictionary) getLabel(lid int32) string {
+	return d.words[lid+d.nwords].word
+}
+
+func (d *dictionary) labels() []string {
+	res := make([]string, 0, d.nlabels)
+	for _, e := range d.words {
+		if e.typ == entryLabel {
+			res = append(res, e.word)
+		}
+	}
+	return res
+}
+
+func (d *dictionary) labelCounts() []int64 {
+	res := make([]int64, 0, d.nlabels)
+	for _, e := range d.words {
+		if e.typ == entryLabel {
+			res = append(res, e.count)
+		}
+	}
+	return res
+}
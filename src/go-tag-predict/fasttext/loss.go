@@ -0,0 +1,217 @@
+package fasttext
+
+import (
+	"container/heap"
+	"math"
+)
+
+const (
+	sigmoidTableSize = 512
+	maxSigmoid       = 8
+)
+
+// scoredLabel : 対数確率とラベルのインデックス
+type scoredLabel struct {
+	score float32
+	label int32
+}
+
+// loss : 出力層の計算 (fastTextのLoss::predictと同じ)
+type loss interface {
+	predict(k int, threshold float32, hidden []float32) []scoredLabel
+}
+
+// stdLog : fastTextのstd_log
+func stdLog(x float32) float32 {
+	return float32(math.Log(float64(x) + 1e-5))
+}
+
+func exp32(x float32) float32 {
+	return float32(math.Exp(float64(x)))
+}
+
+// minHeap : スコアが最小の要素を先頭に保つヒープ
+type minHeap []scoredLabel
+
+func (h minHeap) Len() int            { return len(h) }
+func (h minHeap) Less(i, j int) bool  { return h[i].score < h[j].score }
+func (h minHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *minHeap) Push(x interface{}) { *h = append(*h, x.(scoredLabel)) }
+func (h *minHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// push : 上位k個だけを残す
+func (h *minHeap) push(k int, s scoredLabel) {
+	heap.Push(h, s)
+	if h.Len() > k {
+		heap.Pop(h)
+	}
+}
+
+// findKBest : 確率がthreshold以上で、上位k個のラベルを取得する
+func findKBest(k int, threshold float32, output []float32) []scoredLabel {
+	h := make(minHeap, 0, k+1)
+	for i, v := range output {
+		if v < threshold {
+			continue
+		}
+		score := stdLog(v)
+		if h.Len() == k && score < h[0].score {
+			continue
+		}
+		h.push(k, scoredLabel{score: score, label: int32(i)})
+	}
+	return h
+}
+
+// softmaxLoss : -loss softmax
+type softmaxLoss struct {
+	wo matrix
+}
+
+func (l *softmaxLoss) predict(k int, threshold float32, hidden []float32) []scoredLabel {
+	output := make([]float32, l.wo.rows())
+	// ラベルのないモデルは予測しない
+	if len(output) == 0 {
+		return nil
+	}
+	for i := range output {
+		output[i] = l.wo.dotRow(hidden, int32(i))
+	}
+	max := output[0]
+	for _, v := range output {
+		if v > max {
+			max = v
+		}
+	}
+	z := float32(0)
+	for i, v := range output {
+		output[i] = exp32(v - max)
+		z += output[i]
+	}
+	for i := range output {
+		output[i] /= z
+	}
+	return findKBest(k, threshold, output)
+}
+
+// binaryLogisticLoss : -loss ns / -loss ova
+type binaryLogisticLoss struct {
+	wo       matrix
+	tSigmoid []float32
+}
+
+func newBinaryLogisticLoss(wo matrix) *binaryLogisticLoss {
+	l := &binaryLogisticLoss{wo: wo, tSigmoid: make([]float32, sigmoidTableSize+1)}
+	for i := range l.tSigmoid {
+		x := float32(i*2*maxSigmoid)/sigmoidTableSize - maxSigmoid
+		l.tSigmoid[i] = float32(1.0 / (1.0 + float64(exp32(-x))))
+	}
+	return l
+}
+
+// sigmoid : fastTextと同じく近似表を使う
+func (l *binaryLogisticLoss) sigmoid(x float32) float32 {
+	if x < -maxSigmoid {
+		return 0
+	}
+	if x > maxSigmoid {
+		return 1
+	}
+	i := int64((x + maxSigmoid) * sigmoidTableSize / maxSigmoid / 2)
+	return l.tSigmoid[i]
+}
+
+func (l *binaryLogisticLoss) predict(k int, threshold float32, hidden []float32) []scoredLabel {
+	output := make([]float32, l.wo.rows())
+	for i := range output {
+		output[i] = l.sigmoid(l.wo.dotRow(hidden, int32(i)))
+	}
+	return findKBest(k, threshold, output)
+}
+
+type node struct {
+	parent int32
+	left   int32
+	right  int32
+	count  int64
+	binary bool
+}
+
+// hierarchicalSoftmaxLoss : -loss hs
+// ラベルの頻度からハフマン木を組み立てる
+type hierarchicalSoftmaxLoss struct {
+	wo   matrix
+	osz  int32
+	tree []node
+}
+
+func newHierarchicalSoftmaxLoss(wo matrix, counts []int64) *hierarchicalSoftmaxLoss {
+	l := &hierarchicalSoftmaxLoss{wo: wo, osz: int32(len(counts))}
+	l.buildTree(counts)
+	return l
+}
+
+func (l *hierarchicalSoftmaxLoss) buildTree(counts []int64) {
+	osz := l.osz
+	if osz == 0 {
+		return
+	}
+	l.tree = make([]node, 2*osz-1)
+	for i := range l.tree {
+		l.tree[i] = node{parent: -1, left: -1, right: -1, count: 1e15}
+	}
+	for i := int32(0); i < osz; i++ {
+		l.tree[i].count = counts[i]
+	}
+	leaf := osz - 1
+	n := osz
+	for i := osz; i < 2*osz-1; i++ {
+		mini := [2]int32{}
+		for j := 0; j < 2; j++ {
+			if leaf >= 0 && l.tree[leaf].count < l.tree[n].count {
+				mini[j] = leaf
+				leaf--
+			} else {
+				mini[j] = n
+				n++
+			}
+		}
+		l.tree[i].left = mini[0]
+		l.tree[i].right = mini[1]
+		l.tree[i].count = l.tree[mini[0]].count + l.tree[mini[1]].count
+		l.tree[mini[0]].parent = i
+		l.tree[mini[1]].parent = i
+		l.tree[mini[1]].binary = true
+	}
+}
+
+func (l *hierarchicalSoftmaxLoss) predict(k int, threshold float32, hidden []float32) []scoredLabel {
+	h := make(minHeap, 0, k+1)
+	if l.osz == 0 {
+		return h
+	}
+	l.dfs(k, stdLog(threshold), 2*l.osz-2, 0, &h, hidden)
+	return h
+}
+
+func (l *hierarchicalSoftmaxLoss) dfs(k int, logThreshold float32, n int32, score float32, h *minHeap, hidden []float32) {
+	if score < logThreshold {
+		return
+	}
+	if h.Len() == k && score < (*h)[0].score {
+		return
+	}
+	if l.tree[n].left == -1 && l.tree[n].right == -1 {
+		h.push(k, scoredLabel{score: score, label: n})
+		return
+	}
+	f := l.wo.dotRow(hidden, n-l.osz)
+	f = float32(1.0 / float64(1+exp32(-f)))
+	l.dfs(k, logThreshold, l.tree[n].left, score+stdLog(float32(1.0-float64(f))), h, hidden)
+	l.dfs(k, logThreshold, l.tree[n].right, score+stdLog(f), h, hidden)
+}
@@ -0,0 +1,161 @@
+package fasttext
+
+// matrix : input/output行列
+type matrix interface {
+	rows() int64
+	addRowToVector(x []float32, i int32)
+	dotRow(x []float32, i int32) float32
+}
+
+func readMatrix(br *binaryReader, quant bool) matrix {
+	if quant {
+		return readQuantMatrix(br)
+	}
+	return readDenseMatrix(br)
+}
+
+// denseMatrix : 通常の行列
+type denseMatrix struct {
+	m    int64
+	n    int64
+	data []float32
+}
+
+func readDenseMatrix(br *binaryReader) *denseMatrix {
+	mat := &denseMatrix{}
+	mat.m = br.int64()
+	mat.n = br.int64()
+	if br.err != nil {
+		return mat
+	}
+	mat.data = br.float32s(mat.m * mat.n)
+	return mat
+}
+
+func (mat *denseMatrix) rows() int64 {
+	return mat.m
+}
+
+func (mat *denseMatrix) addRowToVector(x []float32, i int32) {
+	row := mat.data[int64(i)*mat.n : (int64(i)+1)*mat.n]
+	for j, v := range row {
+		x[j] += v
+	}
+}
+
+func (mat *denseMatrix) dotRow(x []float32, i int32) float32 {
+	row := mat.data[int64(i)*mat.n : (int64(i)+1)*mat.n]
+	d := float32(0)
+	for j, v := range row {
+		d += float32(v * x[j]) // 積和演算(FMA)に最適化させない
+	}
+	return d
+}
+
+// quantMatrix : 直積量子化された行列 (fasttext quantizeの出力)
+type quantMatrix struct {
+	qnorm     bool
+	m         int64
+	n         int64
+	codes     []byte
+	pq        *productQuantizer
+	normCodes []byte
+	npq       *productQuantizer
+}
+
+func readQuantMatrix(br *binaryReader) *quantMatrix {
+	mat := &quantMatrix{}
+	mat.qnorm = br.bool()
+	mat.m = br.int64()
+	mat.n = br.int64()
+	codesize := br.int32()
+	if br.err != nil {
+		return mat
+	}
+	mat.codes = br.bytes(int64(codesize))
+	mat.pq = readProductQuantizer(br)
+	if mat.qnorm {
+		mat.normCodes = br.bytes(mat.m)
+		mat.npq = readProductQuantizer(br)
+	}
+	return mat
+}
+
+func (mat *quantMatrix) rows() int64 {
+	return mat.m
+}
+
+func (mat *quantMatrix) norm(i int32) float32 {
+	if !mat.qnorm {
+		return 1
+	}
+	return mat.npq.getCentroids(0, mat.normCodes[i])[0]
+}
+
+func (mat *quantMatrix) addRowToVector(x []float32, i int32) {
+	mat.pq.addcode(x, mat.codes, i, mat.norm(i))
+}
+
+func (mat *quantMatrix) dotRow(x []float32, i int32) float32 {
+	return mat.pq.mulcode(x, mat.codes, i, mat.norm(i))
+}
+
+const (
+	pqNbits = 8
+	pqKsub  = 1 << pqNbits
+)
+
+// productQuantizer : 直積量子化の重心
+type productQuantizer struct {
+	dim       int32
+	nsubq     int32
+	dsub      int32
+	lastdsub  int32
+	centroids []float32
+}
+
+func readProductQuantizer(br *binaryReader) *productQuantizer {
+	pq := &productQuantizer{}
+	pq.dim = br.int32()
+	pq.nsubq = br.int32()
+	pq.dsub = br.int32()
+	pq.lastdsub = br.int32()
+	if br.err != nil {
+		return pq
+	}
+	pq.centroids = br.float32s(int64(pq.dim) * pqKsub)
+	return pq
+}
+
+func (pq *productQuantizer) getCentroids(m int32, i byte) []float32 {
+	if m == pq.nsubq-1 {
+		offset := m*pqKsub*pq.dsub + int32(i)*pq.lastdsub
+		return pq.centroids[offset : offset+pq.lastdsub]
+	}
+	offset := (m*pqKsub + int32(i)) * pq.dsub
+	return pq.centroids[offset : offset+pq.dsub]
+}
+
+func (pq *productQuantizer) addcode(x []float32, codes []byte, t int32, alpha float32) {
+	code := codes[pq.nsubq*t : pq.nsubq*(t+1)]
+	for m := int32(0); m < pq.nsubq; m++ {
+		c := pq.getCentroids(m, code[m])
+		base := m * pq.dsub
+		for n, v := range c {
+			x[base+int32(n)] += float32(alpha * v)
+		}
+	}
+}
+
+func (pq *productQuantizer) mulcode(x []float32, codes []byte, t int32, alpha float32) float32 {
+	res := float32(0)
+	code := codes[pq.nsubq*t : pq.nsubq*(t+1)]
+	for m := int32(0); m < pq.nsubq; m++ {
+		c := pq.getCentroids(m, code[m])
+		base := m * pq.dsub
+		for n, v := range c {
+			res += float32(x[base+int32(n)] * v)
+		}
+	}
+	return res * alpha
+}
@@ -0,0 +1,300 @@
+package fasttext
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	fileFormatMagic      = 793712314
+	fileFormatMaxVersion = 12
+)
+
+// fastTextのloss
+const (
+	lossHS      = 1
+	lossNS      = 2
+	lossSoftmax = 3
+	lossOVA     = 4
+)
+
+// fastTextのmodel
+const (
+	modelCBOW = 1
+	modelSG   = 2
+	modelSup  = 3
+)
+
+// Prediction : 分類結果のラベルと確率
+type Prediction struct {
+	Label       string
+	Probability float64
+}
+
+// args : 学習時のパラメーター
+type args struct {
+	dim          int32
+	ws           int32
+	epoch        int32
+	minCount     int32
+	neg          int32
+	wordNgrams   int32
+	loss         int32
+	model        int32
+	bucket       int32
+	minn         int32
+	maxn         int32
+	lrUpdateRate int32
+	t            float64
+}
+
+// Model : fastTextの学習済みモデル(.bin)
+//
+// predict-probと同じ計算をGoで行うので、外部プログラムのfasttextを必要としない
+// 読み込み後は状態を変更しないので、複数Goルーチンから同時に使える
+// Example:
+//   m, err := fasttext.LoadModel("model.bin")
+//   predictions := m.Predict("1 2 3", 1, 0)
+type Model struct {
+	args   *args
+	dict   *dictionary
+	input  matrix
+	output matrix
+	loss   loss
+}
+
+// LoadModel : ファイルからモデルを読み込む
+func LoadModel(filePath string) (*Model, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	return ReadModel(bufio.NewReaderSize(f, 1024*1024))
+}
+
+// ReadModel : fastTextの.bin形式のモデルを読み込む
+// 量子化済みのモデル(.ftz)にも対応する
+func ReadModel(r io.Reader) (*Model, error) {
+	br := &binaryReader{r: r}
+	magic := br.int32()
+	version := br.int32()
+	if br.err != nil {
+		return nil, br.err
+	}
+	if magic != fileFormatMagic {
+		return nil, errors.New("not a fastText model file")
+	}
+	if version > fileFormatMaxVersion {
+		return nil, errors.New("unsupported fastText model version: " + strconv.Itoa(int(version)))
+	}
+	a := readArgs(br)
+	if br.err != nil {
+		return nil, br.err
+	}
+	if a.model != modelSup {
+		return nil, errors.New("model needs to be supervised for prediction")
+	}
+	if version == 11 {
+		// 古い教師ありモデルは文字n-gramを使わない
+		a.maxn = 0
+	}
+	dict := readDictionary(br, a)
+	if br.err != nil {
+		return nil, br.err
+	}
+
+	quantInput := br.bool()
+	input := readMatrix(br, quantInput)
+	qout := br.bool()
+	output := readMatrix(br, quantInput && qout)
+	if br.err != nil {
+		return nil, br.err
+	}
+
+	m := &Model{args: a, dict: dict, input: input, output: output}
+	switch a.loss {
+	case lossSoftmax:
+		m.loss = &softmaxLoss{wo: output}
+	case lossNS, lossOVA:
+		m.loss = newBinaryLogisticLoss(output)
+	case lossHS:
+		m.loss = newHierarchicalSoftmaxLoss(output, dict.labelCounts())
+	default:
+		return nil, errors.New("unsupported loss: " + strconv.Itoa(int(a.loss)))
+	}
+	return m, nil
+}
+
+func readArgs(br *binaryReader) *args {
+	a := &args{}
+	a.dim = br.int32()
+	a.ws = br.int32()
+	a.epoch = br.int32()
+	a.minCount = br.int32()
+	a.neg = br.int32()
+	a.wordNgrams = br.int32()
+	a.loss = br.int32()
+	a.model = br.int32()
+	a.bucket = br.int32()
+	a.minn = br.int32()
+	a.maxn = br.int32()
+	a.lrUpdateRate = br.int32()
+	a.t = br.float64()
+	return a
+}
+
+// lossNames : fastTextの-lossに指定する名前
+var lossNames = map[int32]string{lossHS: "hs", lossNS: "ns", lossSoftmax: "softmax", lossOVA: "ova"}
+
+// SupervisedArgs : 学習時のパラメーターを、fasttext supervisedの引数の形式で取得する
+// ※学習率(-lr)はモデルファイルに保存されないので含まない
+func (m *Model) SupervisedArgs() []string {
+	a := m.args
+	i := func(v int32) string {
+		return strconv.Itoa(int(v))
+	}
+	return []string{
+		"-dim", i(a.dim),
+		"-ws", i(a.ws),
+		"-epoch", i(a.epoch),
+		"-minCount", i(a.minCount),
+		"-neg", i(a.neg),
+		"-wordNgrams", i(a.wordNgrams),
+		"-loss", lossNames[a.loss],
+		"-bucket", i(a.bucket),
+		"-minn", i(a.minn),
+		"-maxn", i(a.maxn),
+		"-lrUpdateRate", i(a.lrUpdateRate),
+		"-t", strconv.FormatFloat(a.t, 'g', -1, 64),
+	}
+}
+
+// Labels : 学習済みのラベルを頻度順に取得する
+func (m *Model) Labels() []string {
+	return m.dict.labels()
+}
+
+// Predict : 1行分の文書を分類して、確率の高い順にk個のラベルを返す
+//
+// lineはfastTextの入力と同じく空白区切りのトークンを指定する
+// 確率がthreshold未満のラベルは返さない
+func (m *Model) Predict(line string, k int, threshold float64) []Prediction {
+	words := m.dict.getLine(line)
+	if len(words) == 0 || k <= 0 {
+		return []Prediction{}
+	}
+	hidden := m.computeHidden(words)
+	heap := m.loss.predict(k, float32(threshold), hidden)
+	sort.SliceStable(heap, func(i, j int) bool {
+		return heap[i].score > heap[j].score
+	})
+	res := make([]Prediction, len(heap))
+	for i, p := range heap {
+		res[i] = Prediction{
+			Label:       m.dict.getLabel(p.label),
+			Probability: float64(float32(math.Exp(float64(p.score)))),
+		}
+	}
+	return res
+}
+
+func (m *Model) computeHidden(words []int32) []float32 {
+	hidden := make([]float32, m.args.dim)
+	for _, w := range words {
+		m.input.addRowToVector(hidden, w)
+	}
+	a := float32(1.0 / float64(len(words)))
+	for i := range hidden {
+		hidden[i] *= a
+	}
+	return hidden
+}
+
+// binaryReader : リトルエンディアンのバイナリ読み込み
+// 最初のエラーを保持して、以降の読み込みは何もしない
+type binaryReader struct {
+	r   io.Reader
+	err error
+	buf [8]byte
+}
+
+func (br *binaryReader) read(n int) []byte {
+	if br.err != nil {
+		return br.buf[:n]
+	}
+	if _, err := io.ReadFull(br.r, br.buf[:n]); err != nil {
+		br.err = errors.Wrap(err, "bad fastText model file")
+	}
+	return br.buf[:n]
+}
+
+func (br *binaryReader) int32() int32 {
+	return int32(binary.LittleEndian.Uint32(br.read(4)))
+}
+
+func (br *binaryReader) int64() int64 {
+	return int64(binary.LittleEndian.Uint64(br.read(8)))
+}
+
+func (br *binaryReader) int8() int8 {
+	return int8(br.read(1)[0])
+}
+
+func (br *binaryReader) bool() bool {
+	return br.read(1)[0] != 0
+}
+
+func (br *binaryReader) float64() float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(br.read(8)))
+}
+
+func (br *binaryReader) float32s(n int64) []float32 {
+	if br.err != nil {
+		return nil
+	}
+	if n < 0 {
+		br.err = errors.New("bad fastText model file: negative size")
+		return nil
+	}
+	res := make([]float32, n)
+	if err := binary.Read(br.r, binary.LittleEndian, res); err != nil {
+		br.err = errors.Wrap(err, "bad fastText model file")
+	}
+	return res
+}
+
+func (br *binaryReader) bytes(n int64) []byte {
+	if br.err != nil {
+		return nil
+	}
+	if n < 0 {
+		br.err = errors.New("bad fastText model file: negative size")
+		return nil
+	}
+	res := make([]byte, n)
+	if _, err := io.ReadFull(br.r, res); err != nil {
+		br.err = errors.Wrap(err, "bad fastText model file")
+	}
+	return res
+}
+
+// cstring : '\0'終端の文字列
+func (br *binaryReader) cstring() string {
+	s := make([]byte, 0, 32)
+	for br.err == nil {
+		c := br.read(1)[0]
+		if c == 0 {
+			break
+		}
+		s = append(s, c)
+	}
+	return string(s)
+}
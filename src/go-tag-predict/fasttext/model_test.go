@@ -0,0 +1,222 @@
+package fasttext
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testModel : テスト用の小さなモデルを.bin形式で書き出す
+//
+// dim=2, 単語: a b </s>, ラベル: __label__1 __label__2
+// input:  a=[1,0] b=[0,1] </s>=[0,0]
+// output: __label__1=[1,0] __label__2=[0,1]
+func testModel(loss int32, quant bool) []byte {
+	buf := bytes.Buffer{}
+	w := func(v interface{}) {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	w(int32(fileFormatMagic))
+	w(int32(12))
+	// args
+	for _, v := range []int32{2, 5, 5, 1, 5, 1, loss, modelSup, 0, 0, 0, 100} {
+		w(v)
+	}
+	w(float64(1e-4))
+	// dictionary
+	w(int32(5)) // size
+	w(int32(3)) // nwords
+	w(int32(2)) // nlabels
+	w(int64(100))
+	w(int64(-1)) // pruneidx_size
+	for _, e := range []struct {
+		word  string
+		count int64
+		typ   int8
+	}{{"</s>", 10, entryWord}, {"a", 5, entryWord}, {"b", 3, entryWord}, {"__label__1", 7, entryLabel}, {"__label__2", 3, entryLabel}} {
+		buf.WriteString(e.word)
+		buf.WriteByte(0)
+		w(e.count)
+		w(e.typ)
+	}
+	// input
+	w(quant)
+	if quant {
+		w(false) // qnorm
+		w(int64(3))
+		w(int64(2))
+		w(int32(3)) // codesize
+		buf.Write([]byte{2, 0, 1})
+		// product quantizer: dim=2, nsubq=1, dsub=2, lastdsub=2
+		for _, v := range []int32{2, 1, 2, 2} {
+			w(v)
+		}
+		centroids := make([]float32, 2*pqKsub)
+		centroids[0] = 1 // code 0 = [1,0]
+		centroids[3] = 1 // code 1 = [0,1]
+		w(centroids)
+	} else {
+		w(int64(3))
+		w(int64(2))
+		w([]float32{0, 0, 1, 0, 0, 1})
+	}
+	// output
+	w(false)
+	w(int64(2))
+	w(int64(2))
+	w([]float32{1, 0, 0, 1})
+	return buf.Bytes()
+}
+
+func ExampleReadModel() {
+	for _, c := range []struct {
+		name  string
+		loss  int32
+		quant bool
+	}{
+		{"softmax", lossSoftmax, false},
+		{"hs", lossHS, false},
+		{"ova", lossOVA, false},
+		{"softmax quantized", lossSoftmax, true},
+	} {
+		m, err := ReadModel(bytes.NewReader(testModel(c.loss, c.quant)))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println(c.name, m.Labels())
+		for _, line := range []string{"a", "b b", "a b", "unknown", ""} {
+			fmt.Printf("%q", line)
+			for _, p := range m.Predict(line, 2, 0) {
+				fmt.Printf(" %s %.5f", p.Label, p.Probability)
+			}
+			fmt.Println()
+		}
+	}
+	m, _ := ReadModel(bytes.NewReader(testModel(lossSoftmax, false)))
+	fmt.Println(m.Predict("a", 1, 0))
+	fmt.Println(m.Predict("a", 2, 0.5))
+
+	fmt.Println(m.SupervisedArgs())
+
+	_, err := ReadModel(bytes.NewReader([]byte("__label__1 a b")))
+	fmt.Println(err)
+	_, err = ReadModel(bytes.NewReader(testModel(lossSoftmax, false)[:100]))
+	fmt.Println(err)
+
+	// Output:
+	// softmax [__label__1 __label__2]
+	// "a" __label__1 0.62247 __label__2 0.37755
+	// "b b" __label__2 0.66077 __label__1 0.33925
+	// "a b" __label__1 0.50001 __label__2 0.50001
+	// "unknown" __label__1 0.50001 __label__2 0.50001
+	// "" __label__1 0.50001 __label__2 0.50001
+	// hs [__label__1 __label__2]
+	// "a" __label__1 0.62247 __label__2 0.37755
+	// "b b" __label__2 0.50001 __label__1 0.50001
+	// "a b" __label__1 0.58258 __label__2 0.41744
+	// "unknown" __label__2 0.50001 __label__1 0.50001
+	// "" __label__2 0.50001 __label__1 0.50001
+	// ova [__label__1 __label__2]
+	// "a" __label__1 0.62247 __label__2 0.50001
+	// "b b" __label__2 0.65843 __label__1 0.50001
+	// "a b" __label__1 0.57751 __label__2 0.57751
+	// "unknown" __label__1 0.50001 __label__2 0.50001
+	// "" __label__1 0.50001 __label__2 0.50001
+	// softmax quantized [__label__1 __label__2]
+	// "a" __label__1 0.62247 __label__2 0.37755
+	// "b b" __label__2 0.66077 __label__1 0.33925
+	// "a b" __label__1 0.50001 __label__2 0.50001
+	// "unknown" __label__1 0.50001 __label__2 0.50001
+	// "" __label__1 0.50001 __label__2 0.50001
+	// [{__label__1 0.6224693655967712}]
+	// [{__label__1 0.6224693655967712}]
+	// [-dim 2 -ws 5 -epoch 5 -minCount 1 -neg 5 -wordNgrams 1 -loss softmax -bucket 0 -minn 0 -maxn 0 -lrUpdateRate 100 -t 0.0001]
+	// not a fastText model file
+	// bad fastText model file: unexpected EOF
+}
+
+// ラベルのないモデルは、どの損失関数でも予測しない
+func Example_lossWithoutLabels() {
+	wo := &denseMatrix{m: 0, n: 2}
+	hidden := []float32{1, 0}
+	fmt.Println(len((&softmaxLoss{wo: wo}).predict(2, 0, hidden)))
+	fmt.Println(len(newBinaryLogisticLoss(wo).predict(2, 0, hidden)))
+	fmt.Println(len(newHierarchicalSoftmaxLoss(wo, nil).predict(2, 0, hidden)))
+	// Output:
+	// 0
+	// 0
+	// 0
+}
+
+func Example_hash() {
+	// fastTextと同じFNV-1a (バイトは符号付き)
+	fmt.Println(hash("a"))
+	fmt.Println(hash("あ"))
+	// Output:
+	// 3826002220
+	// 3792067761
+}
+
+// TestModel_predictProb : testdataの本物のfastTextのモデルで、predict-probの出力と同じ結果になるか
+// testdataはbin/make_fasttext_testdata.shで作る
+func TestModel_predictProb(t *testing.T) {
+	inputs := readLines(t, filepath.Join("testdata", "input.txt"))
+	for _, name := range []string{"softmax.bin", "hs.bin", "ova.bin", "ngram.bin", "ngram.ftz"} {
+		modelPath := filepath.Join("testdata", name)
+		if _, err := os.Stat(modelPath); os.IsNotExist(err) {
+			t.Skipf("%s not found. run bin/make_fasttext_testdata.sh", modelPath)
+		}
+		m, err := LoadModel(modelPath)
+		if err != nil {
+			t.Fatalf("%s: %+v", name, err)
+		}
+		expected := readLines(t, modelPath+".predict-prob")
+		if len(expected) != len(inputs) {
+			t.Fatalf("%s: %d lines, want %d", name, len(expected), len(inputs))
+		}
+		for i, line := range inputs {
+			want, err := ParsePredictLine(expected[i])
+			if err != nil {
+				t.Fatalf("%s: %+v", name, err)
+			}
+			got := m.Predict(line, 3, 0)
+			if !samePredictions(got, want) {
+				t.Errorf("%s %q: got %v, want %v", name, line, got, want)
+			}
+		}
+	}
+}
+
+func readLines(t *testing.T, filePath string) []string {
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+// samePredictions : predict-probは確率を6桁で出力するので、その精度で比較する
+// 確率が同じラベルは順序が入れ替わることがあるので、ラベルと確率の組で比較する
+func samePredictions(got, want []Prediction) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	probabilities := map[string]float64{}
+	for _, p := range want {
+		probabilities[p.Label] = p.Probability
+	}
+	for _, p := range got {
+		w, ok := probabilities[p.Label]
+		if !ok || math.Abs(p.Probability-w) > 1e-5+1e-5*w {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package fasttext
+
+import (
+	"bufio"
+	"context"
+	"go-tag-predict/osutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ProcessPredictor : fasttext predict-probを常駐させて分類する
+//
+// 1文書を1行として標準入力へ送り、標準出力の1行を結果として受け取る
+// Example:
+//   p := fasttext.NewProcessPredictor(ctx, "/usr/local/bin/fasttext",
+//     []string{"predict-prob", "model.bin", "-", "3"}, 4, 30*time.Second)
+//   defer p.Close()
+//   predictions, err := p.Predict(ctx, "1 2 3")
+//   // predictions[0] == Prediction{Label: "__label__12", Probability: 0.53125}
+type ProcessPredictor struct {
+	pool *osutil.ProcessPool
+}
+
+// NewProcessPredictor : コンストラクタ
+//
+// argsは標準入力("-")から読み込むpredict-probの引数を指定する
+func NewProcessPredictor(ctx context.Context, command string, args []string, processCount int, timeout time.Duration) *ProcessPredictor {
+	return &ProcessPredictor{
+		pool: osutil.NewProcessPool(ctx, command, args, processCount, timeout),
+	}
+}
+
+// 1文書を1行で送るので、改行は空白に置き換える
+var lineReplacer = strings.NewReplacer("\r", " ", "\n", " ")
+
+// PredictLine : 1文書分の分類結果の行を取得する
+func (p *ProcessPredictor) PredictLine(ctx context.Context, tokens string) (string, error) {
+	var line string
+	err := p.pool.Do(ctx, lineReplacer.Replace(tokens)+"\n", func(r *bufio.Reader) error {
+		s, err := r.ReadString('\n')
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		line = strings.TrimRight(s, "\r\n")
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return line, nil
+}
+
+// Predict : 1文書を分類して、確率の高い順にラベルを返す
+func (p *ProcessPredictor) Predict(ctx context.Context, tokens string) ([]Prediction, error) {
+	line, err := p.PredictLine(ctx, tokens)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePredictLine(line)
+}
+
+// ParsePredictLine : predict-probの出力1行を解析する
+//
+// k > 1の場合は、1行に複数のラベルと確率の組が並ぶ
+//   __label__12 0.53125 __label__3 0.25 __label__7 0.125
+// 古いfastTextは空行に"n/a"を出力するので、空の出力と同じく分類結果なしとする
+func ParsePredictLine(line string) ([]Prediction, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 1 && fields[0] == "n/a" {
+		return []Prediction{}, nil
+	}
+	if len(fields)%2 != 0 {
+		return nil, errors.New("bad fasttext output: " + line)
+	}
+	res := make([]Prediction, 0, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		if !strings.HasPrefix(fields[i], labelPrefix) {
+			return nil, errors.New("bad fasttext output: " + line)
+		}
+		probability, err := strconv.ParseFloat(fields[i+1], 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "bad fasttext output: "+line)
+		}
+		res = append(res, Prediction{Label: fields[i], Probability: probability})
+	}
+	return res, nil
+}
+
+// Close : 常駐しているfasttextを終了する
+func (p *ProcessPredictor) Close() error {
+	return p.pool.Close()
+}
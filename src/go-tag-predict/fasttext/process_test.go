@@ -0,0 +1,35 @@
+package fasttext
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func ExampleParsePredictLine() {
+	fmt.Println(ParsePredictLine("__label__12 0.53125"))
+	fmt.Println(ParsePredictLine("__label__12 0.53125 __label__3 0.25 __label__7 0.125"))
+	fmt.Println(ParsePredictLine(""))
+	fmt.Println(ParsePredictLine("n/a"))
+	fmt.Println(ParsePredictLine("__label__12 0.53125 __label__3"))
+	fmt.Println(ParsePredictLine("12 0.53125"))
+	// Output:
+	// [{__label__12 0.53125}] <nil>
+	// [{__label__12 0.53125} {__label__3 0.25} {__label__7 0.125}] <nil>
+	// [] <nil>
+	// [] <nil>
+	// [] bad fasttext output: __label__12 0.53125 __label__3
+	// [] bad fasttext output: 12 0.53125
+}
+
+func ExampleProcessPredictor() {
+	ctx := context.Background()
+	// predict-probの代わりに、1行ごとに固定の結果を返す
+	p := NewProcessPredictor(ctx, "sh", []string{"-c", `while read l; do echo "__label__1 0.5 __label__2 0.25"; done`}, 2, 5*time.Second)
+	defer p.Close()
+	fmt.Println(p.Predict(ctx, "1 2\n3"))
+	fmt.Println(p.Predict(ctx, "4 5 6"))
+	// Output:
+	// [{__label__1 0.5} {__label__2 0.25}] <nil>
+	// [{__label__1 0.5} {__label__2 0.25}] <nil>
+}
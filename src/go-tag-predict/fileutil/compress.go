@@ -0,0 +1,82 @@
+package fileutil
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// 圧縮形式を判定するマジックナンバー
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Open : ファイルを開く
+// gzip(.gz)かzstd(.zst)で圧縮されている場合は、ファイルの先頭のマジックナンバーで判定して、展開しながら読み込む
+//
+// Example:
+//   r, err := fileutil.Open("bookmarks.xml.gz")
+//   if err != nil {
+//     return err
+//   }
+//   defer r.Close()
+func Open(name string) (io.ReadCloser, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	r, err := NewDecompressReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &readCloser{Reader: r, closers: []io.Closer{r, f}}, nil
+}
+
+// NewDecompressReader : gzipかzstdで圧縮されている場合は、展開しながら読み込むReaderを返す
+// 圧縮されていない場合はそのまま読み込む
+// ※rはCloseしないので、呼び出し元で閉じる
+func NewDecompressReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, errors.WithStack(err)
+	}
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return gr, nil
+	case bytes.HasPrefix(head, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return zr.IOReadCloser(), nil
+	}
+	return &readCloser{Reader: br}, nil
+}
+
+// readCloser : Close時に、closersを順番に閉じる
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *readCloser) Close() error {
+	var err error
+	for _, c := range r.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return errors.WithStack(err)
+}
@@ -0,0 +1,56 @@
+package fileutil
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+func ExampleOpen() {
+	dir, _ := ioutil.TempDir("", "fileutil")
+	defer os.RemoveAll(dir)
+
+	plain := filepath.Join(dir, "posts.xml")
+	ioutil.WriteFile(plain, []byte("<posts />"), 0600)
+
+	gz := filepath.Join(dir, "posts.xml.gz")
+	f, _ := os.Create(gz)
+	gw := gzip.NewWriter(f)
+	gw.Write([]byte("<posts>gzip</posts>"))
+	gw.Close()
+	f.Close()
+
+	zst := filepath.Join(dir, "posts.xml.zst")
+	f, _ = os.Create(zst)
+	zw, _ := zstd.NewWriter(f)
+	zw.Write([]byte("<posts>zstd</posts>"))
+	zw.Close()
+	f.Close()
+
+	empty := filepath.Join(dir, "empty.xml")
+	ioutil.WriteFile(empty, nil, 0600)
+
+	for _, name := range []string{plain, gz, zst, empty} {
+		r, err := Open(name)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		body, err := ioutil.ReadAll(r)
+		fmt.Printf("%q %v %v\n", body, err, r.Close())
+	}
+	_, err := Open(filepath.Join(dir, "notexists.xml"))
+	fmt.Println(os.IsNotExist(errors.Cause(err)))
+
+	// Output:
+	// "<posts />" <nil> <nil>
+	// "<posts>gzip</posts>" <nil> <nil>
+	// "<posts>zstd</posts>" <nil> <nil>
+	// "" <nil> <nil>
+	// true
+}
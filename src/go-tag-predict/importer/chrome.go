@@ -0,0 +1,101 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"go-tag-predict/webservice/pinboard"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	Register(&Importer{
+		Name: "chrome",
+		Sniff: func(head []byte) bool {
+			return bytes.HasPrefix(bytes.TrimSpace(head), []byte("{")) && bytes.Contains(head, []byte(`"roots"`))
+		},
+		Parse: parseChrome,
+	})
+}
+
+// chromeNode : ChromeのBookmarksファイルのフォルダ or ブックマーク
+type chromeNode struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// DateAdded : 1601-01-01(UTC)からのマイクロ秒
+	DateAdded string        `json:"date_added"`
+	Children  []*chromeNode `json:"children"`
+}
+
+// parseChrome : ChromeのBookmarksファイル(JSON)
+// ブックマークバーなどのルートより下のフォルダ名をタグにする
+//   {"roots": {"bookmark_bar": {"type": "folder", "children": [{"type": "folder", "name": "golang", "children": [{"type": "url", "name": "title", "url": "https://..."}]}]}, ...}}
+func parseChrome(r io.Reader) (pinboard.PostIterator, error) {
+	v := struct {
+		Roots map[string]*chromeNode `json:"roots"`
+	}{}
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if v.Roots == nil {
+		return nil, errors.New("bad data")
+	}
+	itr := &postSlice{}
+	for _, name := range []string{"bookmark_bar", "other", "synced"} {
+		if root, ok := v.Roots[name]; ok {
+			itr.posts = appendChromePosts(itr.posts, root.Children, nil)
+		}
+	}
+	return itr, nil
+}
+
+func appendChromePosts(posts []*pinboard.Post, nodes []*chromeNode, folders []string) []*pinboard.Post {
+	for _, n := range nodes {
+		switch n.Type {
+		case "folder":
+			posts = appendChromePosts(posts, n.Children, append(folders[:len(folders):len(folders)], folderTag(n.Name)))
+		case "url":
+			if n.URL == "" {
+				continue
+			}
+			title := n.Name
+			if title == "" {
+				title = n.URL
+			}
+			posts = append(posts, &pinboard.Post{Title: title, Href: n.URL, Tags: nonEmpty(folders), Time: chromeTime(n.DateAdded)})
+		}
+	}
+	return posts
+}
+
+// chromeEpochOffset : 1601-01-01から1970-01-01までの秒数
+const chromeEpochOffset = 11644473600
+
+// chromeTime : date_addedを変換する。空 or 不正な形式の場合はゼロ値
+func chromeTime(s string) time.Time {
+	us, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || us <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(us/1000000-chromeEpochOffset, us%1000000*1000).UTC()
+}
+
+// folderTag : フォルダ名をタグにする (タグは空白区切りなので、空白は"_"に置き換える)
+func folderTag(name string) string {
+	return strings.Join(strings.Fields(name), "_")
+}
+
+func nonEmpty(ar []string) []string {
+	res := make([]string, 0, len(ar))
+	for _, s := range ar {
+		if s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
@@ -0,0 +1,38 @@
+package importer
+
+import (
+	"strings"
+)
+
+func Example_parseChrome() {
+	body := `{
+		"checksum": "x",
+		"roots": {
+			"bookmark_bar": {"type": "folder", "name": "Bookmarks bar", "children": [
+				{"type": "url", "name": "a", "url": "https://1", "date_added": "13134567890123456"},
+				{"type": "folder", "name": "golang", "children": [
+					{"type": "url", "name": "b", "url": "https://2"},
+					{"type": "folder", "name": "machine learning", "children": [
+						{"type": "url", "name": "", "url": "https://3"}
+					]}
+				]},
+				{"type": "folder", "name": "web", "children": [
+					{"type": "url", "name": "d", "url": "https://4"}
+				]}
+			]},
+			"other": {"type": "folder", "name": "Other bookmarks", "children": [
+				{"type": "url", "name": "e", "url": "https://5"}
+			]},
+			"synced": {"type": "folder", "name": "Mobile bookmarks", "children": []}
+		},
+		"version": 1
+	}`
+	printPosts(parseChrome(strings.NewReader(body)))
+
+	// Output:
+	// https://1 a 0 [] time=2017-03-21T11:04:50Z
+	// https://2 b 1 [golang]
+	// https://3 https://3 2 [golang machine_learning]
+	// https://4 d 1 [web]
+	// https://5 e 0 []
+}
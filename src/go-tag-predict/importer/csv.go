@@ -0,0 +1,140 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"go-tag-predict/webservice/pinboard"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CSVの列名の候補 (大文字/小文字は区別しない。先にあるものを優先する)
+var (
+	csvURLColumns   = []string{"url", "href", "link", "uri"}
+	csvTitleColumns = []string{"title", "name", "description"}
+	csvTagsColumns  = []string{"tags", "tag", "labels", "label"}
+	// メモ
+	csvExtendedColumns = []string{"extended", "note", "notes", "comment", "excerpt"}
+	// ブックマークした日時 (RFC3339, "2006-01-02 15:04:05", "2006-01-02"など。数字だけの場合はUNIX時間(秒))
+	csvTimeColumns = []string{"time", "created", "created_at", "date", "added", "add_date"}
+)
+
+func init() {
+	Register(&Importer{
+		Name: "csv",
+		// 他の形式に当てはまらない場合だけCSVとみなす
+		Priority: 10,
+		Sniff: func(head []byte) bool {
+			line := head
+			if i := bytes.IndexAny(head, "\r\n"); i >= 0 {
+				line = head[:i]
+			}
+			header, err := csv.NewReader(bytes.NewReader(line)).Read()
+			return err == nil && csvColumn(header, csvURLColumns) >= 0
+		},
+		Parse: parseCSV,
+	})
+}
+
+// csvIterator : 1行ずつ読み込む
+type csvIterator struct {
+	nopClose
+	r        *csv.Reader
+	url      int
+	title    int
+	tags     int
+	extended int
+	time     int
+}
+
+// parseCSV : 1行目が列名のCSV
+// URL(url, href, link or uri)の列は必須。タイトル(title, name or description), タグ(tags, tag, labels or label), メモ, 日時の列は省略できる
+// タグは","か";"区切り (どちらも含まない場合は空白区切り)
+//   url,title,tags
+//   https://...,title,"tag1,tag2"
+func parseCSV(r io.Reader) (pinboard.PostIterator, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	itr := &csvIterator{
+		r:        cr,
+		url:      csvColumn(header, csvURLColumns),
+		title:    csvColumn(header, csvTitleColumns),
+		tags:     csvColumn(header, csvTagsColumns),
+		extended: csvColumn(header, csvExtendedColumns),
+		time:     csvColumn(header, csvTimeColumns),
+	}
+	if itr.url < 0 {
+		return nil, errors.New("bad data: url column not found")
+	}
+	return itr, nil
+}
+
+// csvColumn : 候補の列名に一致する列の位置 (ない場合は-1)
+func csvColumn(header []string, names []string) int {
+	for _, name := range names {
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(h, "\xef\xbb\xbf")), name) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func (itr *csvIterator) Next() (*pinboard.Post, error) {
+	for {
+		record, err := itr.r.Read()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		href := itr.column(record, itr.url)
+		if href == "" {
+			continue
+		}
+		title := itr.column(record, itr.title)
+		if title == "" {
+			title = href
+		}
+		return &pinboard.Post{
+			Title:    title,
+			Href:     href,
+			Tags:     splitCSVTags(itr.column(record, itr.tags)),
+			Extended: itr.column(record, itr.extended),
+			Time:     parseTime(itr.column(record, itr.time)),
+		}, nil
+	}
+}
+
+func (itr *csvIterator) column(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// splitCSVTags : タグの列を分割する
+// Example:
+//   splitCSVTags("tag1, machine learning") // []string{"tag1", "machine_learning"}
+//   splitCSVTags("tag1 tag2")              // []string{"tag1", "tag2"}
+func splitCSVTags(s string) []string {
+	if !strings.ContainsAny(s, ",;") {
+		return strings.Fields(s)
+	}
+	tags := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ';'
+	})
+	for i, tag := range tags {
+		tags[i] = folderTag(tag)
+	}
+	return nonEmpty(tags)
+}
@@ -0,0 +1,25 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+)
+
+func Example_parseCSV() {
+	body := "id,Title,Note,URL,Tags,Created\n" +
+		"1,a,note,https://1,\"go, machine learning\",2017-04-01 12:00:00\n" +
+		"2,,,https://2,web にほんご,1490000000\n" +
+		"3,c,,,go\n" +
+		"4,d,,https://4\n"
+	printPosts(parseCSV(strings.NewReader(body)))
+	printPosts(parseCSV(strings.NewReader("title,tags\na,b\n")))
+
+	fmt.Println(splitCSVTags("tag1; tag2"))
+
+	// Output:
+	// https://1 a 2 [go machine_learning] extended="note" time=2017-04-01T12:00:00Z
+	// https://2 https://2 2 [web にほんご] time=2017-03-20T08:53:20Z
+	// https://4 d 0 []
+	// bad data: url column not found
+	// [tag1 tag2]
+}
@@ -0,0 +1,139 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"go-tag-predict/webservice/pinboard"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/html/charset"
+)
+
+func init() {
+	Register(&Importer{
+		Name: "hatena",
+		Sniff: func(head []byte) bool {
+			return bytes.Contains(head, []byte("<feed"))
+		},
+		Parse: parseHatena,
+	})
+}
+
+// hatenaEntry : はてなブックマークのエクスポート(Atom)の<entry>要素
+type hatenaEntry struct {
+	Title string `xml:"title"`
+	Links []struct {
+		Rel  string `xml:"rel,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"link"`
+	Summary string `xml:"summary"`
+	// Subjects : <dc:subject>のタグ
+	Subjects []string `xml:"subject"`
+	// Issued : ブックマークした日時 (Atom 0.3)
+	Issued string `xml:"issued"`
+	// Published : ブックマークした日時 (Atom 1.0)
+	Published string `xml:"published"`
+}
+
+// href : ブックマークしたページのURL
+// rel="alternate"ははてなブックマークのページなので、rel="related"を優先する
+func (e *hatenaEntry) href() string {
+	alternate := ""
+	for _, l := range e.Links {
+		switch l.Rel {
+		case "related":
+			return l.Href
+		case "", "alternate":
+			if alternate == "" {
+				alternate = l.Href
+			}
+		}
+	}
+	return alternate
+}
+
+// summaryTagsPattern : コメントの先頭の[tag1][tag2]
+var summaryTagsPattern = regexp.MustCompile(`^(\[[^\[\]]+\])+`)
+
+// tags : <dc:subject>がない場合は、コメントの先頭の[tag]をタグにする
+func (e *hatenaEntry) tags() []string {
+	if len(e.Subjects) > 0 {
+		return nonEmpty(e.Subjects)
+	}
+	m := summaryTagsPattern.FindString(strings.TrimSpace(e.Summary))
+	if m == "" {
+		return nil
+	}
+	return nonEmpty(strings.Split(strings.Trim(m, "[]"), "]["))
+}
+
+// comment : コメントの先頭の[tag]を除いたもの
+func (e *hatenaEntry) comment() string {
+	s := strings.TrimSpace(e.Summary)
+	return strings.TrimSpace(s[len(summaryTagsPattern.FindString(s)):])
+}
+
+// time : ブックマークした日時
+func (e *hatenaEntry) time() time.Time {
+	if e.Issued != "" {
+		return parseTime(e.Issued)
+	}
+	return parseTime(e.Published)
+}
+
+// hatenaIterator : <entry>要素を1件ずつデコードする
+type hatenaIterator struct {
+	nopClose
+	dec *xml.Decoder
+}
+
+// parseHatena : はてなブックマークのエクスポート(Atom)
+//   <feed xmlns="http://purl.org/atom/ns#" xmlns:dc="http://purl.org/dc/elements/1.1/">
+//     <entry>
+//       <title>title</title>
+//       <link type="text/html" rel="related" href="https://..." />
+//       <issued>2017-04-01T09:00:00+09:00</issued>
+//       <summary>[tag1][tag2]comment</summary>
+//       <dc:subject>tag1</dc:subject>
+//       <dc:subject>tag2</dc:subject>
+//     </entry>
+//   </feed>
+func parseHatena(r io.Reader) (pinboard.PostIterator, error) {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = charset.NewReaderLabel
+	return &hatenaIterator{dec: dec}, nil
+}
+
+func (itr *hatenaIterator) Next() (*pinboard.Post, error) {
+	for {
+		t, err := itr.dec.Token()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		se, ok := t.(xml.StartElement)
+		if !ok || se.Name.Local != "entry" {
+			continue
+		}
+		e := &hatenaEntry{}
+		if err := itr.dec.DecodeElement(e, &se); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		href := e.href()
+		if href == "" {
+			continue
+		}
+		title := strings.TrimSpace(e.Title)
+		if title == "" {
+			title = href
+		}
+		return &pinboard.Post{Title: title, Href: href, Tags: e.tags(), Extended: e.comment(), Time: e.time()}, nil
+	}
+}
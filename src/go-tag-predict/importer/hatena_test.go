@@ -0,0 +1,38 @@
+package importer
+
+import (
+	"strings"
+)
+
+func Example_parseHatena() {
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<feed version="0.3" xmlns="http://purl.org/atom/ns#" xmlns:dc="http://purl.org/dc/elements/1.1/" xml:lang="ja">
+	<title>user's bookmarks</title>
+	<entry>
+		<title>a</title>
+		<link type="text/html" rel="related" href="https://1" />
+		<link type="text/html" rel="alternate" href="https://b.hatena.ne.jp/user/1" />
+		<issued>2017-04-01T09:00:00+09:00</issued>
+		<summary>[go][web]comment</summary>
+		<dc:subject>go</dc:subject>
+		<dc:subject>web</dc:subject>
+	</entry>
+	<entry>
+		<title>b</title>
+		<link type="text/html" rel="related" href="https://2" />
+		<summary>[にほんご][memo]comment [not tag]</summary>
+	</entry>
+	<entry>
+		<title>c</title>
+		<link type="text/html" href="https://3" />
+		<summary>comment</summary>
+	</entry>
+</feed>
+`
+	printPosts(parseHatena(strings.NewReader(body)))
+
+	// Output:
+	// https://1 a 2 [go web] extended="comment" time=2017-04-01T00:00:00Z
+	// https://2 b 2 [にほんご memo] extended="comment [not tag]"
+	// https://3 c 0 [] extended="comment"
+}
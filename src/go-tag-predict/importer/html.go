@@ -0,0 +1,208 @@
+package importer
+
+import (
+	"bytes"
+	"go-tag-predict/webservice/pinboard"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/html"
+)
+
+func init() {
+	Register(&Importer{
+		Name: "netscape",
+		Sniff: func(head []byte) bool {
+			return bytes.Contains(bytes.ToLower(head), []byte("netscape-bookmark-file"))
+		},
+		Parse: func(r io.Reader) (pinboard.PostIterator, error) {
+			return &htmlIterator{z: html.NewTokenizer(r), folders: true}, nil
+		},
+	})
+	Register(&Importer{
+		Name: "pocket",
+		// Netscape形式と同じように<a>を並べたHTMLなので、Netscape形式の後に判定する
+		Priority: 1,
+		Sniff: func(head []byte) bool {
+			return bytes.Contains(head, []byte("Pocket Export")) || (bytes.Contains(head, []byte("<ul")) && bytes.Contains(head, []byte("time_added")))
+		},
+		Parse: func(r io.Reader) (pinboard.PostIterator, error) {
+			return &htmlIterator{z: html.NewTokenizer(r), folders: false}, nil
+		},
+	})
+}
+
+// htmlIterator : <a href="..." tags="tag1,tag2">title</a>を並べたHTMLからブックマークを取得する
+//
+// Netscape形式(ブラウザ, Raindrop, Diigo, Pinboardなどのエクスポート):
+//   <DL><p>
+//     <DT><H3>folder</H3>
+//     <DL><p>
+//       <DT><A HREF="https://..." ADD_DATE="1500000000" TAGS="tag1,tag2" PRIVATE="0" TOREAD="0">title</A>
+//       <DD>memo
+//     </DL><p>
+//   </DL><p>
+// TAGS属性がないブックマーク(ブラウザのエクスポート)は、フォルダ名をタグにする (foldersがtrueの場合)
+// <DD>はメモなので、次のブックマークかフォルダが始まるまで返さずに待つ
+//
+// Pocketのエクスポート:
+//   <h1>Unread</h1>
+//   <ul>
+//     <li><a href="https://..." time_added="1500000000" tags="tag1,tag2">title</a></li>
+//   </ul>
+//   <h1>Read Archive</h1>
+// Unreadのブックマークは「あとで読む」にする
+type htmlIterator struct {
+	nopClose
+	z       *html.Tokenizer
+	folders bool
+	// stack : <DL>ごとのフォルダ名
+	stack []string
+	// pending : 直前の<H3>のフォルダ名 (次の<DL>で使う)
+	pending string
+	// section : 直前の<h1>の見出し
+	section string
+	// cur : <DD>を待っているブックマーク
+	cur *pinboard.Post
+	// replay : 読み込み済みのトークンを、次のループでもう一度処理する
+	replay bool
+	tt     html.TokenType
+}
+
+func (itr *htmlIterator) Next() (*pinboard.Post, error) {
+	z := itr.z
+	for {
+		if !itr.replay {
+			itr.tt = z.Next()
+		}
+		itr.replay = false
+		switch itr.tt {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return itr.flush(), nil
+			}
+			return nil, errors.WithStack(z.Err())
+		case html.StartTagToken:
+			name, attrs := itr.tag()
+			switch name {
+			case "h1":
+				itr.section = strings.TrimSpace(itr.text("h1"))
+			case "h3":
+				itr.pending = folderTag(itr.text("h3"))
+				// ブラウザのブックマークバーなどのルートのフォルダは、タグにしない
+				if _, ok := attrs["personal_toolbar_folder"]; ok {
+					itr.pending = ""
+				}
+				if _, ok := attrs["unfiled_bookmarks_folder"]; ok {
+					itr.pending = ""
+				}
+				if p := itr.flush(); p != nil {
+					return p, nil
+				}
+			case "dl":
+				itr.stack = append(itr.stack, itr.pending)
+				itr.pending = ""
+			case "dd":
+				if itr.cur != nil {
+					itr.cur.Extended = strings.TrimSpace(itr.untilTag())
+				}
+			case "a":
+				post := itr.post(attrs, strings.TrimSpace(itr.text("a")))
+				if post == nil {
+					continue
+				}
+				p := itr.flush()
+				itr.cur = post
+				if p != nil {
+					return p, nil
+				}
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if string(name) == "dl" && len(itr.stack) > 0 {
+				itr.stack = itr.stack[:len(itr.stack)-1]
+			}
+		}
+	}
+}
+
+// flush : <DD>を待っているブックマークを返す
+func (itr *htmlIterator) flush() *pinboard.Post {
+	p := itr.cur
+	itr.cur = nil
+	return p
+}
+
+// post : <a>の属性からブックマークを生成する
+func (itr *htmlIterator) post(attrs map[string]string, title string) *pinboard.Post {
+	href := attrs["href"]
+	if href == "" {
+		return nil
+	}
+	if title == "" {
+		title = href
+	}
+	post := &pinboard.Post{
+		Title:   title,
+		Href:    href,
+		Private: attrs["private"] == "1",
+		ToRead:  attrs["toread"] == "1" || itr.section == "Unread",
+	}
+	if t, ok := attrs["add_date"]; ok {
+		post.Time = parseTime(t)
+	} else {
+		post.Time = parseTime(attrs["time_added"])
+	}
+	tags, ok := attrs["tags"]
+	if ok || !itr.folders {
+		post.Tags = nonEmpty(strings.Split(tags, ","))
+	} else {
+		post.Tags = nonEmpty(itr.stack)
+	}
+	return post
+}
+
+// tag : 開始タグの名前と属性
+func (itr *htmlIterator) tag() (string, map[string]string) {
+	name, hasAttr := itr.z.TagName()
+	attrs := map[string]string{}
+	for hasAttr {
+		var k, v []byte
+		k, v, hasAttr = itr.z.TagAttr()
+		attrs[string(k)] = strings.TrimSpace(string(v))
+	}
+	return string(name), attrs
+}
+
+// untilTag : 次のタグまでのテキスト
+// 読み込んだタグは、次のループでもう一度処理する
+func (itr *htmlIterator) untilTag() string {
+	var b strings.Builder
+	for {
+		itr.tt = itr.z.Next()
+		if itr.tt != html.TextToken {
+			itr.replay = true
+			return b.String()
+		}
+		b.Write(itr.z.Text())
+	}
+}
+
+// text : 終了タグまでのテキスト
+func (itr *htmlIterator) text(name string) string {
+	var b strings.Builder
+	for {
+		switch itr.z.Next() {
+		case html.ErrorToken:
+			return b.String()
+		case html.TextToken:
+			b.Write(itr.z.Text())
+		case html.EndTagToken:
+			if n, _ := itr.z.TagName(); string(n) == name {
+				return b.String()
+			}
+		}
+	}
+}
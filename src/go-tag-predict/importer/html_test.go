@@ -0,0 +1,62 @@
+package importer
+
+import (
+	"strings"
+)
+
+func Example_netscape() {
+	body := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+	<DT><H3 ADD_DATE="1490000000" PERSONAL_TOOLBAR_FOLDER="true">Bookmarks bar</H3>
+	<DL><p>
+		<DT><A HREF="https://1" ADD_DATE="1490000000">a &amp; b</A>
+		<DT><H3 ADD_DATE="1490000000">Go Lang</H3>
+		<DL><p>
+			<DT><A HREF="https://2" ADD_DATE="1490000000">c</A>
+		</DL><p>
+		<DT><A HREF="https://3" ADD_DATE="1490000000" TAGS="web,にほんご" PRIVATE="0" TOREAD="1">d</A>
+		<DD>description &amp; memo
+		<DT><A HREF="https://4" ADD_DATE="1490000000" TAGS="secret" PRIVATE="1">e</A>
+	</DL><p>
+	<DT><A HREF="https://5" ADD_DATE="1490000000" TAGS="">f</A>
+</DL><p>
+`
+	imp := importers["netscape"]
+	printPosts(imp.Parse(strings.NewReader(body)))
+
+	// Output:
+	// https://1 a & b 0 [] time=2017-03-20T08:53:20Z
+	// https://2 c 1 [Go_Lang] time=2017-03-20T08:53:20Z
+	// https://3 d 2 [web にほんご] extended="description & memo" time=2017-03-20T08:53:20Z toread
+	// https://4 e 1 [secret] time=2017-03-20T08:53:20Z private
+	// https://5 f 0 [] time=2017-03-20T08:53:20Z
+}
+
+func Example_pocket() {
+	body := `<!DOCTYPE html>
+<html>
+	<head><title>Pocket Export</title></head>
+	<body>
+		<h1>Unread</h1>
+		<ul>
+			<li><a href="https://1" time_added="1500000000" tags="go,web">a</a></li>
+			<li><a href="https://2" time_added="1500000000" tags="">b</a></li>
+		</ul>
+		<h1>Read Archive</h1>
+		<ul>
+			<li><a href="https://3" time_added="1500000000">c</a></li>
+		</ul>
+	</body>
+</html>
+`
+	imp := importers["pocket"]
+	printPosts(imp.Parse(strings.NewReader(body)))
+
+	// Output:
+	// https://1 a 2 [go web] time=2017-07-14T02:40:00Z toread
+	// https://2 b 0 [] time=2017-07-14T02:40:00Z toread
+	// https://3 c 0 [] time=2017-07-14T02:40:00Z
+}
@@ -0,0 +1,228 @@
+package importer
+
+import (
+	"bytes"
+	"go-tag-predict/fileutil"
+	"go-tag-predict/webservice/pinboard"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FormatAuto : ファイルの先頭部分から形式を判定する
+const FormatAuto = "auto"
+
+// sniffLen : 形式の判定に使うファイルの先頭部分の長さ
+const sniffLen = 4096
+
+// Importer : ブックマークのエクスポートファイルの読み込み方
+type Importer struct {
+	// Name : learning_source_formatで指定する名前
+	Name string
+	// Priority : 形式を判定する順番 (小さい順)。他の形式と紛らわしいものほど大きくする
+	Priority int
+	// Sniff : ファイルの先頭部分から、この形式かどうかを判定する
+	Sniff func(head []byte) bool
+	// Parse : ブックマークを逐次取得するイテレータを返す
+	// rはイテレータのCloseで閉じずに、呼び出し元で閉じる
+	Parse func(r io.Reader) (pinboard.PostIterator, error)
+}
+
+var (
+	mutex     sync.RWMutex
+	importers = map[string]*Importer{}
+)
+
+// Register : 読み込み方を登録する
+// 同じ名前の読み込み方は上書きする
+func Register(imp *Importer) {
+	defer mutex.Unlock()
+	mutex.Lock()
+	importers[imp.Name] = imp
+}
+
+// Formats : 登録されている形式の名前
+func Formats() []string {
+	defer mutex.RUnlock()
+	mutex.RLock()
+	res := make([]string, 0, len(importers))
+	for name := range importers {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// IsFormat : 登録されている形式(or FormatAuto)かどうか
+func IsFormat(format string) bool {
+	if format == "" || format == FormatAuto {
+		return true
+	}
+	defer mutex.RUnlock()
+	mutex.RLock()
+	_, ok := importers[format]
+	return ok
+}
+
+// Sniff : ファイルの先頭部分から形式を判定する
+// どの形式にも当てはまらない場合は空文字を返す
+func Sniff(head []byte) string {
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")) // BOM
+	defer mutex.RUnlock()
+	mutex.RLock()
+	candidates := make([]*Importer, 0, len(importers))
+	for _, imp := range importers {
+		candidates = append(candidates, imp)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Priority != candidates[j].Priority {
+			return candidates[i].Priority < candidates[j].Priority
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+	for _, imp := range candidates {
+		if imp.Sniff != nil && imp.Sniff(head) {
+			return imp.Name
+		}
+	}
+	return ""
+}
+
+// LoadFile : ファイルからブックマークを取得する
+// formatが空 or FormatAutoの場合は、ファイルの先頭部分から形式を判定する
+// ファイル全体をメモリに読み込まずに、逐次パースする。gzip(.gz)かzstd(.zst)で圧縮されたファイルはそのまま展開しながら読み込む
+// 読み終わったらCloseでファイルを閉じる
+//
+// Example:
+//   itr, err := importer.LoadFile("bookmarks.html.gz", importer.FormatAuto)
+//   if err != nil {
+//     return err
+//   }
+//   defer itr.Close()
+func LoadFile(filePath string, format string) (pinboard.PostIterator, error) {
+	r, err := fileutil.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	itr, err := Parse(r, format)
+	if err != nil {
+		r.Close()
+		return nil, errors.Wrap(err, filePath)
+	}
+	return &fileIterator{PostIterator: itr, c: r}, nil
+}
+
+// fileIterator : Closeでファイルも閉じる
+type fileIterator struct {
+	pinboard.PostIterator
+	c io.Closer
+}
+
+func (itr *fileIterator) Close() error {
+	err := itr.PostIterator.Close()
+	if e := itr.c.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// Parse : rからブックマークを取得する
+// formatが空 or FormatAutoの場合は、先頭部分から形式を判定する
+// rはCloseしないので、呼び出し元で閉じる
+func Parse(r io.Reader, format string) (pinboard.PostIterator, error) {
+	if format == "" || format == FormatAuto {
+		br := newPeekReader(r)
+		head, err := br.peek(sniffLen)
+		if err != nil {
+			return nil, err
+		}
+		if format = Sniff(head); format == "" {
+			return nil, errors.New("unknown bookmark format")
+		}
+		r = br
+	}
+	mutex.RLock()
+	imp, ok := importers[format]
+	mutex.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("bad bookmark format: %q", format)
+	}
+	return imp.Parse(r)
+}
+
+// peekReader : 先頭部分を読んでも、最初から読み直せるReader
+type peekReader struct {
+	io.Reader
+	r io.Reader
+}
+
+func newPeekReader(r io.Reader) *peekReader {
+	return &peekReader{Reader: r, r: r}
+}
+
+func (p *peekReader) peek(n int) ([]byte, error) {
+	head := make([]byte, n)
+	l, err := io.ReadFull(p.r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, errors.WithStack(err)
+	}
+	head = head[:l]
+	p.Reader = io.MultiReader(bytes.NewReader(head), p.r)
+	return head, nil
+}
+
+// nopClose : 読み込み元を閉じないイテレータのClose
+type nopClose struct{}
+
+func (nopClose) Close() error {
+	return nil
+}
+
+// postSlice : 読み込み済みのブックマークを順番に返すイテレータ
+type postSlice struct {
+	nopClose
+	posts []*pinboard.Post
+	i     int
+}
+
+func (s *postSlice) Next() (*pinboard.Post, error) {
+	if s.i >= len(s.posts) {
+		return nil, nil
+	}
+	p := s.posts[s.i]
+	s.i++
+	return p, nil
+}
+
+// timeLayouts : parseTimeで試す日時の形式
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+	"2006-01-02",
+	"2006/01/02",
+}
+
+// parseTime : 日時の文字列を変換する。数字だけの場合はUNIX時間(秒)とみなす
+// 空 or 不正な形式の場合はゼロ値を返す
+func parseTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if sec <= 0 {
+			return time.Time{}
+		}
+		return time.Unix(sec, 0).UTC()
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,99 @@
+package importer
+
+import (
+	"compress/gzip"
+	"fmt"
+	"go-tag-predict/webservice/pinboard"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func printPosts(itr pinboard.PostIterator, err error) {
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer itr.Close()
+	for {
+		post, err := itr.Next()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if post == nil {
+			break
+		}
+		line := strings.TrimSuffix(fmt.Sprintln(post.Href, post.Title, len(post.Tags), post.Tags), "\n")
+		if post.Extended != "" {
+			line += fmt.Sprintf(" extended=%q", post.Extended)
+		}
+		if !post.Time.IsZero() {
+			line += " time=" + post.Time.UTC().Format(time.RFC3339)
+		}
+		if post.Private {
+			line += " private"
+		}
+		if post.ToRead {
+			line += " toread"
+		}
+		fmt.Println(line)
+	}
+}
+
+func ExampleSniff() {
+	fmt.Println(Sniff([]byte(`<?xml version="1.0" encoding="UTF-8" ?><posts user="user">`)))
+	fmt.Println(Sniff([]byte(` [{"href":"https://1"}]`)))
+	fmt.Println(Sniff([]byte(`{"checksum": "", "roots": {}}`)))
+	fmt.Println(Sniff([]byte("\xef\xbb\xbf<!DOCTYPE NETSCAPE-Bookmark-file-1>")))
+	fmt.Println(Sniff([]byte(`<!DOCTYPE html><html><head><title>Pocket Export</title>`)))
+	fmt.Println(Sniff([]byte(`<feed version="0.3" xmlns="http://purl.org/atom/ns#">`)))
+	fmt.Println(Sniff([]byte("Title,URL,Tags\r\na,https://1,b")))
+	fmt.Printf("%q\n", Sniff([]byte("title,tags\na,b")))
+	fmt.Println(Formats())
+
+	// Output:
+	// pinboard_xml
+	// pinboard_json
+	// chrome
+	// netscape
+	// pocket
+	// hatena
+	// csv
+	// ""
+	// [chrome csv hatena netscape pinboard_json pinboard_xml pocket]
+}
+
+func ExampleLoadFile() {
+	dir, _ := ioutil.TempDir("", "importer")
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "bookmarks.csv")
+	ioutil.WriteFile(filePath, []byte("url,title,tags\nhttps://1,a,go\n"), 0600)
+
+	// 圧縮されたファイルは展開しながら判定する
+	gzPath := filepath.Join(dir, "bookmarks.json.gz")
+	f, _ := os.Create(gzPath)
+	gw := gzip.NewWriter(f)
+	gw.Write([]byte(`[{"href":"https://2","description":"b","tags":"web"}]`))
+	gw.Close()
+	f.Close()
+
+	printPosts(LoadFile(filePath, FormatAuto))
+	printPosts(LoadFile(gzPath, FormatAuto))
+	printPosts(LoadFile(filePath, "csv"))
+	_, err := LoadFile(filePath, "pinboard_json")
+	fmt.Println(err != nil)
+	_, err = LoadFile(filePath, "xxx")
+	fmt.Println(strings.Contains(err.Error(), `bad bookmark format: "xxx"`))
+	fmt.Println(IsFormat(""), IsFormat(FormatAuto), IsFormat("pocket"), IsFormat("xxx"))
+
+	// Output:
+	// https://1 a 1 [go]
+	// https://2 b 1 [web]
+	// https://1 a 1 [go]
+	// true
+	// true
+	// true true true false
+}
@@ -0,0 +1,89 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"go-tag-predict/webservice/pinboard"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	Register(&Importer{
+		Name: "pinboard_xml",
+		Sniff: func(head []byte) bool {
+			return bytes.Contains(head, []byte("<posts"))
+		},
+		Parse: pinboard.Parse,
+	})
+	Register(&Importer{
+		Name: "pinboard_json",
+		Sniff: func(head []byte) bool {
+			return bytes.HasPrefix(bytes.TrimSpace(head), []byte("["))
+		},
+		Parse: parsePinboardJSON,
+	})
+}
+
+// pinboardJSONPost : Pinboardのエクスポート(JSON)の1件
+type pinboardJSONPost struct {
+	Href        string `json:"href"`
+	Description string `json:"description"`
+	Extended    string `json:"extended"`
+	Tags        string `json:"tags"`
+	Time        string `json:"time"`
+	Hash        string `json:"hash"`
+	Meta        string `json:"meta"`
+	Shared      string `json:"shared"`
+	ToRead      string `json:"toread"`
+}
+
+// pinboardJSONIterator : 配列の要素を1件ずつデコードする
+type pinboardJSONIterator struct {
+	nopClose
+	dec *json.Decoder
+}
+
+// parsePinboardJSON : Pinboardのエクスポート(JSON)
+//   [{"href":"https://...","description":"title","tags":"tag1 tag2","shared":"yes",...}, ...]
+func parsePinboardJSON(r io.Reader) (pinboard.PostIterator, error) {
+	dec := json.NewDecoder(r)
+	t, err := dec.Token()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if d, ok := t.(json.Delim); !ok || d != '[' {
+		return nil, errors.New("bad data")
+	}
+	return &pinboardJSONIterator{dec: dec}, nil
+}
+
+func (itr *pinboardJSONIterator) Next() (*pinboard.Post, error) {
+	for itr.dec.More() {
+		p := &pinboardJSONPost{}
+		if err := itr.dec.Decode(p); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if p.Href == "" {
+			continue
+		}
+		title := p.Description
+		if title == "" {
+			title = p.Href
+		}
+		return &pinboard.Post{
+			Title:    title,
+			Href:     p.Href,
+			Tags:     strings.Fields(p.Tags),
+			Extended: p.Extended,
+			Time:     parseTime(p.Time),
+			Hash:     p.Hash,
+			Meta:     p.Meta,
+			Private:  p.Shared == "no",
+			ToRead:   p.ToRead == "yes",
+		}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,24 @@
+package importer
+
+import (
+	"strings"
+)
+
+func Example_parsePinboardJSON() {
+	body := `[
+		{"href":"https://1","description":"a","extended":"","meta":"x","hash":"y","time":"2017-03-01T00:00:00Z","shared":"yes","toread":"no","tags":"go web"},
+		{"href":"https://2","description":"b","shared":"no","tags":"private"},
+		{"href":"https://3","description":"c","shared":"yes","tags":""},
+		{"href":"https://4","description":"","tags":"notitle"},
+		{"href":"","description":"nourl"}
+	]`
+	printPosts(parsePinboardJSON(strings.NewReader(body)))
+	printPosts(parsePinboardJSON(strings.NewReader(`{}`)))
+
+	// Output:
+	// https://1 a 2 [go web] time=2017-03-01T00:00:00Z
+	// https://2 b 1 [private] private
+	// https://3 c 0 []
+	// https://4 https://4 1 [notitle]
+	// bad data
+}
@@ -8,8 +8,10 @@ import (
 	"go-tag-predict/fileutil"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"syscall"
 
 	"github.com/pkg/errors"
 
@@ -21,6 +23,10 @@ import (
 
 var configPath *string
 var isDebugMode = flag.Bool("debug", false, "debug mode")
+var outputFormat = flag.String("output-format", "", "predict output format (jsonl, csv or tsv). overrides [predict] output_format")
+var outputPath = flag.String("o", "", "predict output file path (\"-\" is stdout). overrides [predict] output_file")
+var isRetrain = flag.Bool("retrain", false, "retrain the model after the tags command")
+var isDryRun = flag.Bool("dry-run", false, "print Pinboard API calls instead of saving bookmarks. overrides [predict.writeback] dry_run and [retag] dry_run")
 
 func init() {
 	configPath = flag.String("f", fileutil.FindFilePath("go-tag-predict.toml"), "configuration file name")
@@ -37,6 +43,11 @@ func main() {
 		fmt.Printf("Commands:\n")
 		fmt.Printf("  supervised  学習モード\n")
 		fmt.Printf("  predict     分類モード\n")
+		fmt.Printf("  serve       分類APIサーバーモード\n")
+		fmt.Printf("  evaluate    評価モード (テスト用データでprecision/recall@kを集計)\n")
+		fmt.Printf("  tune        ハイパーパラメーター探索モード\n")
+		fmt.Printf("  retag       既存のブックマークのタグ付け直しモード\n")
+		fmt.Printf("  tags        タグの変更モード (tags rename OLD NEW | tags merge A B ... into C | tags drop X ...)\n")
 		fmt.Printf("  help        Print this message\n")
 		fmt.Printf("\n")
 		fmt.Printf("Run '%s COMMAND --help' for more information on the command\n", filepath.Base(os.Args[0]))
@@ -46,22 +57,55 @@ func main() {
 	}
 
 	flag.Parse()
+
+	// SIGINT/SIGTERMでctxをキャンセルして、常駐処理を終了させる
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		logger.Info("signal received")
+		cancel()
+	}()
+
 	if *isDebugMode {
 		logger.Info("debug mode")
 		go func() {
-			run(context.Background(), logger)
+			checkErrorExit(run(ctx, logger))
 		}()
 		// http://localhost:6060/debug/pprof/
 		// http://localhost:6060/debug/pprof/goroutine?debug=1
 		log.Println(http.ListenAndServe("localhost:6060", nil))
 	} else {
-		run(context.Background(), logger)
+		checkErrorExit(run(ctx, logger))
 	}
 }
-func run(ctx context.Context, logger *zap.Logger) {
+
+// errInvalidCommand : 使い方は表示済みなので、エラーメッセージを出さずに終了する
+var errInvalidCommand = errors.New("invalid command")
+
+// run : コマンドを実行する
+// os.Exitするとdeferが実行されず、Juman++などのプロセスが残るので、エラーは呼び出し元に返す
+func run(ctx context.Context, logger *zap.Logger) error {
 	logger.Info("start", zap.Int("numCPU", runtime.NumCPU()), zap.Int("maxProcs", runtime.GOMAXPROCS(0)))
 	config, err := app.LoadConfig(*configPath)
-	checkErrorExit(err)
+	if err != nil {
+		return err
+	}
+	defer config.Close()
+	if *outputFormat != "" {
+		config.Predict.OutputFormat = *outputFormat
+	}
+	if *outputPath != "" {
+		config.Predict.OutputFilePath = *outputPath
+	}
+	if *isDryRun && config.Predict.Writeback != nil {
+		config.Predict.Writeback.DryRun = true
+	}
+	if *isDryRun {
+		config.Retag.DryRun = true
+	}
 
 	command := ""
 	// command := "predict" // debug
@@ -73,20 +117,35 @@ func run(ctx context.Context, logger *zap.Logger) {
 	switch command {
 	case "supervised":
 		err = app.RunSupervised(ctx, config, logger)
-		checkErrorExit(err)
 	case "predict":
 		err = app.RunPredict(ctx, config, logger)
-		checkErrorExit(err)
+	case "serve":
+		err = app.RunServe(ctx, config, logger)
+	case "evaluate":
+		err = app.RunEvaluate(ctx, config, logger)
+	case "tune":
+		err = app.RunTune(ctx, config, logger)
+	case "retag":
+		err = app.RunRetag(ctx, config, logger)
+	case "tags":
+		err = app.RunTags(ctx, config, logger, args[1:], *isRetrain)
 	case "help":
 		flag.Usage()
 	default:
-		fmt.Printf("%q is not valid command (supervised or predict).\n\n", command)
+		fmt.Printf("%q is not valid command (supervised, predict, serve, evaluate, tune, retag or tags).\n\n", command)
 		flag.Usage()
-		os.Exit(1)
+		return errInvalidCommand
+	}
+	if err != nil {
+		return err
 	}
 	logger.Info("finish")
+	return nil
 }
 func checkErrorExit(err error) {
+	if err == errInvalidCommand {
+		os.Exit(1)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%+v\n", err)
 		os.Exit(1)
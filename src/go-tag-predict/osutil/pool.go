@@ -0,0 +1,205 @@
+package osutil
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrPoolClosed : Close済みのProcessPoolを使おうとした
+var ErrPoolClosed = errors.New("process pool closed")
+
+// ProcessPool : 標準入出力で対話する外部プログラムを常駐させるプール
+//
+// 1つのプロセスは同時に1つのリクエストだけを処理するので、応答の順番は入力と一致する
+// プロセスが異常終了したりタイムアウトした場合は、そのプロセスを破棄して次の利用時に起動し直す
+// Example:
+//   pool := osutil.NewProcessPool(ctx, "cat", nil, 4, 10*time.Second)
+//   defer pool.Close()
+//   var line string
+//   err := pool.Do(ctx, "input\n", func(r *bufio.Reader) error {
+//     var err error
+//     line, err = r.ReadString('\n')
+//     return err
+//   })
+type ProcessPool struct {
+	name    string
+	args    []string
+	timeout time.Duration
+	slots   chan *process
+	size    int
+	ctx     context.Context
+	cancel  context.CancelFunc
+	once    sync.Once
+}
+
+type process struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	pipe   io.ReadCloser
+	stdout *bufio.Reader
+}
+
+// NewProcessPool : コンストラクタ
+//
+// プロセスは最初に使われた時に起動する
+// ctxがキャンセルされると、全てのプロセスを終了する
+func NewProcessPool(ctx context.Context, name string, args []string, size int, timeout time.Duration) *ProcessPool {
+	if size < 1 {
+		size = 1
+	}
+	p := &ProcessPool{
+		name:    name,
+		args:    args,
+		timeout: timeout,
+		slots:   make(chan *process, size),
+		size:    size,
+	}
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	for i := 0; i < size; i++ {
+		p.slots <- nil // 未起動
+	}
+	go func() {
+		<-p.ctx.Done()
+		p.Close()
+	}()
+	return p
+}
+
+// Do : 空いているプロセスへinputを送り、応答をreadで読み取る
+//
+// readは1リクエスト分の応答だけを読み取ること
+// 起動済みのプロセスが既に終了していた場合は、起動し直して1回だけ再試行する
+func (p *ProcessPool) Do(ctx context.Context, input string, read func(r *bufio.Reader) error) error {
+	var proc *process
+	select {
+	case proc = <-p.slots:
+	case <-p.ctx.Done():
+		return errors.WithStack(ErrPoolClosed)
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+
+	var err error
+	for retry := 0; retry < 2; retry++ {
+		started := false
+		if proc == nil {
+			proc, err = p.start()
+			if err != nil {
+				break
+			}
+			started = true
+		}
+		err = p.do(ctx, proc, input, read)
+		if err == nil {
+			break
+		}
+		proc.kill()
+		proc = nil
+		if started || ctx.Err() != nil || p.ctx.Err() != nil {
+			break
+		}
+		if _, ok := errors.Cause(err).(*timeoutError); ok {
+			break
+		}
+	}
+	p.slots <- proc
+	return err
+}
+
+type timeoutError struct {
+	timeout time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	return "process timeout: " + e.timeout.String()
+}
+
+func (p *ProcessPool) do(ctx context.Context, proc *process, input string, read func(r *bufio.Reader) error) error {
+	c := make(chan error, 1)
+	go func() {
+		if _, err := io.WriteString(proc.stdin, input); err != nil {
+			c <- errors.WithStack(err)
+			return
+		}
+		c <- read(proc.stdout)
+	}()
+
+	var timeout <-chan time.Time
+	if p.timeout > 0 {
+		timer := time.NewTimer(p.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var err error
+	select {
+	case err = <-c:
+		return err
+	case <-timeout:
+		err = errors.WithStack(&timeoutError{timeout: p.timeout})
+	case <-ctx.Done():
+		err = errors.WithStack(ctx.Err())
+	case <-p.ctx.Done():
+		err = errors.WithStack(ErrPoolClosed)
+	}
+	// 読み書き中のGoルーチンは、プロセスを終了させてパイプを閉じるまで待つ
+	// ※子プロセスがパイプを掴んだままのことがあるので、こちら側からも閉じる
+	proc.cmd.Process.Kill()
+	proc.stdin.Close()
+	proc.pipe.Close()
+	<-c
+	return err
+}
+
+func (p *ProcessPool) start() (*process, error) {
+	cmd := exec.CommandContext(p.ctx, p.name, p.args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &process{cmd: cmd, stdin: stdin, pipe: stdout, stdout: bufio.NewReaderSize(stdout, 1024*64)}, nil
+}
+
+// stop : 標準入力を閉じて、プロセスの終了を待つ
+func (proc *process) stop() {
+	proc.stdin.Close()
+	proc.cmd.Wait()
+}
+
+// kill : プロセスを強制終了する
+func (proc *process) kill() {
+	proc.stdin.Close()
+	proc.cmd.Process.Kill()
+	proc.cmd.Wait()
+}
+
+// Close : 全てのプロセスを終了する
+// 処理中のリクエストがある場合は、その完了を待つ
+func (p *ProcessPool) Close() error {
+	p.once.Do(func() {
+		// 処理中のリクエストをキャンセルしないように、先に全てのプロセスを回収する
+		procs := make([]*process, 0, p.size)
+		for i := 0; i < p.size; i++ {
+			procs = append(procs, <-p.slots)
+		}
+		p.cancel()
+		for _, proc := range procs {
+			if proc != nil {
+				proc.stop()
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,75 @@
+package osutil
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+func readLine(line *string) func(r *bufio.Reader) error {
+	return func(r *bufio.Reader) error {
+		s, err := r.ReadString('\n')
+		*line = strings.TrimSpace(s)
+		return err
+	}
+}
+
+func ExampleProcessPool() {
+	ctx := context.Background()
+	pool := NewProcessPool(ctx, "cat", nil, 3, 5*time.Second)
+
+	wg := sync.WaitGroup{}
+	res := make([]string, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pool.Do(ctx, fmt.Sprintf("line %d\n", i), readLine(&res[i]))
+		}(i)
+	}
+	wg.Wait()
+	fmt.Println(res[0], res[9])
+
+	fmt.Println(pool.Close())
+	var line string
+	fmt.Println(pool.Do(ctx, "closed\n", readLine(&line)))
+
+	// Output:
+	// line 0 line 9
+	// <nil>
+	// process pool closed
+}
+
+func ExampleProcessPool_restart() {
+	ctx := context.Background()
+	// 1行だけ応答して終了する
+	pool := NewProcessPool(ctx, "sh", []string{"-c", "read l; echo $l"}, 1, 5*time.Second)
+	defer pool.Close()
+
+	var line string
+	fmt.Println(pool.Do(ctx, "1\n", readLine(&line)), line)
+	// 終了したプロセスは起動し直して再試行する
+	fmt.Println(pool.Do(ctx, "2\n", readLine(&line)), line)
+
+	// Output:
+	// <nil> 1
+	// <nil> 2
+}
+
+func ExampleProcessPool_timeout() {
+	ctx := context.Background()
+	pool := NewProcessPool(ctx, "sh", []string{"-c", "read l; sleep 10"}, 1, 100*time.Millisecond)
+	defer pool.Close()
+
+	var line string
+	start := time.Now()
+	fmt.Println(pool.Do(ctx, "1\n", readLine(&line)))
+	fmt.Println(time.Since(start) < 5*time.Second)
+
+	// Output:
+	// process timeout: 100ms
+	// true
+}
@@ -0,0 +1,250 @@
+package stemmer
+
+// Porter : Porterのステミングで、英単語の語尾を取り除いて語幹にする
+// (connection, connected, connecting => connect)
+//
+// M.F. Porter, "An algorithm for suffix stripping" (1980) の実装
+// 小文字のASCII以外を含む単語と、2文字以下の単語はそのまま返す
+func Porter(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+	p := &porter{b: []byte(word), k: len(word) - 1}
+	p.step1ab()
+	if p.k > 0 {
+		p.step1c()
+		p.step2()
+		p.step3()
+		p.step4()
+		p.step5()
+	}
+	return string(p.b[:p.k+1])
+}
+
+// porter : b[0..k]がステミング中の単語, jは語尾を取り除いた語幹の末尾
+type porter struct {
+	b []byte
+	k int
+	j int
+}
+
+// cons : b[i]が子音か (yは直前が子音の場合は母音)
+func (p *porter) cons(i int) bool {
+	switch p.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !p.cons(i-1)
+	}
+	return true
+}
+
+// m : b[0..j]を[C](VC){m}[V]とみなした時のmの値
+func (p *porter) m() int {
+	n, i := 0, 0
+	for ; i <= p.j && p.cons(i); i++ {
+	}
+	for {
+		for ; i <= p.j && !p.cons(i); i++ {
+		}
+		if i > p.j {
+			return n
+		}
+		n++
+		for ; i <= p.j && p.cons(i); i++ {
+		}
+		if i > p.j {
+			return n
+		}
+	}
+}
+
+// vowelInStem : b[0..j]に母音が含まれるか
+func (p *porter) vowelInStem() bool {
+	for i := 0; i <= p.j; i++ {
+		if !p.cons(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// doublec : b[i-1..i]が同じ子音の並びか
+func (p *porter) doublec(i int) bool {
+	return i >= 1 && p.b[i] == p.b[i-1] && p.cons(i)
+}
+
+// cvc : b[i-2..i]が子音-母音-子音で、最後の子音がw, x, yでないか (hop, cav, lovなど)
+func (p *porter) cvc(i int) bool {
+	if i < 2 || !p.cons(i) || p.cons(i-1) || !p.cons(i-2) {
+		return false
+	}
+	switch p.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+// ends : b[0..k]がsで終わるか。終わる場合は、jをsの直前にする
+func (p *porter) ends(s string) bool {
+	l := len(s)
+	if l > p.k+1 || string(p.b[p.k-l+1:p.k+1]) != s {
+		return false
+	}
+	p.j = p.k - l
+	return true
+}
+
+// setTo : b[j+1..k]をsに置き換える
+func (p *porter) setTo(s string) {
+	p.b = append(p.b[:p.j+1], s...)
+	p.k = p.j + len(s)
+}
+
+// replace : suffixesの中で最初に一致した語尾を、m() > 0の場合に置き換える
+func (p *porter) replace(suffixes [][2]string) {
+	for _, s := range suffixes {
+		if p.ends(s[0]) {
+			if p.m() > 0 {
+				p.setTo(s[1])
+			}
+			return
+		}
+	}
+}
+
+// step1ab : 複数形と過去形/進行形の語尾を取り除く
+// caresses => caress, ponies => poni, cats => cat, agreed => agree, plastered => plaster, motoring => motor, hopping => hop
+func (p *porter) step1ab() {
+	if p.b[p.k] == 's' {
+		switch {
+		case p.ends("sses"):
+			p.k -= 2
+		case p.ends("ies"):
+			p.setTo("i")
+		case p.b[p.k-1] != 's':
+			p.k--
+		}
+	}
+	if p.ends("eed") {
+		if p.m() > 0 {
+			p.k--
+		}
+		return
+	}
+	if !(p.ends("ed") || p.ends("ing")) || !p.vowelInStem() {
+		return
+	}
+	p.k = p.j
+	switch {
+	case p.ends("at"):
+		p.setTo("ate")
+	case p.ends("bl"):
+		p.setTo("ble")
+	case p.ends("iz"):
+		p.setTo("ize")
+	case p.doublec(p.k):
+		switch p.b[p.k] {
+		case 'l', 's', 'z':
+		default:
+			p.k--
+		}
+	case p.m() == 1 && p.cvc(p.k):
+		p.setTo("e")
+	}
+}
+
+// step1c : 語幹に母音がある場合は、語尾のyをiにする (happy => happi)
+func (p *porter) step1c() {
+	if p.ends("y") && p.vowelInStem() {
+		p.b[p.k] = 'i'
+	}
+}
+
+// step2Suffixes : 最後から2文字目 => step2で置き換える語尾
+var step2Suffixes = map[byte][][2]string{
+	'a': {{"ational", "ate"}, {"tional", "tion"}},
+	'c': {{"enci", "ence"}, {"anci", "ance"}},
+	'e': {{"izer", "ize"}},
+	'g': {{"logi", "log"}},
+	'l': {{"bli", "ble"}, {"alli", "al"}, {"entli", "ent"}, {"eli", "e"}, {"ousli", "ous"}},
+	'o': {{"ization", "ize"}, {"ation", "ate"}, {"ator", "ate"}},
+	's': {{"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"}, {"ousness", "ous"}},
+	't': {{"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"}},
+}
+
+// step2 : 二重の語尾を1つにする (relational => relate, conditional => condition)
+func (p *porter) step2() {
+	if p.k < 1 {
+		return
+	}
+	p.replace(step2Suffixes[p.b[p.k-1]])
+}
+
+// step3Suffixes : 最後の文字 => step3で置き換える語尾
+var step3Suffixes = map[byte][][2]string{
+	'e': {{"icate", "ic"}, {"ative", ""}, {"alize", "al"}},
+	'i': {{"iciti", "ic"}},
+	'l': {{"ical", "ic"}, {"ful", ""}},
+	's': {{"ness", ""}},
+}
+
+// step3 : -ic-, -full, -nessなどを処理する (electrical => electric, hopeful => hope)
+func (p *porter) step3() {
+	p.replace(step3Suffixes[p.b[p.k]])
+}
+
+// step4Suffixes : 最後から2文字目 => step4で取り除く語尾
+var step4Suffixes = map[byte][]string{
+	'a': {"al"},
+	'c': {"ance", "ence"},
+	'e': {"er"},
+	'i': {"ic"},
+	'l': {"able", "ible"},
+	'n': {"ant", "ement", "ment", "ent"},
+	'o': {"ion", "ou"},
+	's': {"ism"},
+	't': {"ate", "iti"},
+	'u': {"ous"},
+	'v': {"ive"},
+	'z': {"ize"},
+}
+
+// step4 : m() > 1の場合に、-ant, -enceなどの語尾を取り除く (adjustment => adjust)
+func (p *porter) step4() {
+	if p.k < 1 {
+		return
+	}
+	for _, s := range step4Suffixes[p.b[p.k-1]] {
+		if !p.ends(s) {
+			continue
+		}
+		// -ionは直前がsかtの場合だけ取り除く
+		if s == "ion" && (p.j < 0 || (p.b[p.j] != 's' && p.b[p.j] != 't')) {
+			continue
+		}
+		if p.m() > 1 {
+			p.k = p.j
+		}
+		return
+	}
+}
+
+// step5 : m() > 1の場合に語尾のeを取り除き、-llを-lにする (probate => probat, controll => control)
+func (p *porter) step5() {
+	p.j = p.k
+	if p.b[p.k] == 'e' {
+		if a := p.m(); a > 1 || (a == 1 && !p.cvc(p.k-1)) {
+			p.k--
+		}
+	}
+	if p.b[p.k] == 'l' && p.doublec(p.k) && p.m() > 1 {
+		p.k--
+	}
+}
@@ -0,0 +1,44 @@
+package stemmer
+
+import "fmt"
+
+func ExamplePorter() {
+	for _, word := range []string{
+		"caresses", "ponies", "cats", "feed", "agreed", "plastered", "motoring", "sing",
+		"conflated", "troubled", "sized", "hopping", "falling", "hissing", "filing", "happy",
+		"relational", "conditional", "generalization", "electrical", "hopeful", "adjustment",
+		"adoption", "probate", "controlling", "connections", "is", "Running", "café",
+	} {
+		fmt.Println(word, Porter(word))
+	}
+	// Output:
+	// caresses caress
+	// ponies poni
+	// cats cat
+	// feed feed
+	// agreed agre
+	// plastered plaster
+	// motoring motor
+	// sing sing
+	// conflated conflat
+	// troubled troubl
+	// sized size
+	// hopping hop
+	// falling fall
+	// hissing hiss
+	// filing file
+	// happy happi
+	// relational relat
+	// conditional condit
+	// generalization gener
+	// electrical electr
+	// hopeful hope
+	// adjustment adjust
+	// adoption adopt
+	// probate probat
+	// controlling control
+	// connections connect
+	// is is
+	// Running Running
+	// café café
+}
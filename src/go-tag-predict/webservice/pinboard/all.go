@@ -1,12 +1,14 @@
 package pinboard
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"go-tag-predict/fileutil"
 	"go-tag-predict/webtools"
 	"io"
-	"io/ioutil"
 	"strings"
+	"time"
 
 	"golang.org/x/net/html/charset"
 
@@ -19,6 +21,16 @@ type Post struct {
 	Title string
 	Href  string
 	Tags  []string
+	// Extended : ブックマークに付けたメモ
+	Extended string
+	// Time : ブックマークした日時 (不明な場合はゼロ値)
+	Time time.Time
+	Hash string
+	Meta string
+	// Private : 非公開 (shared="no")
+	Private bool
+	// ToRead : あとで読む (toread="yes")
+	ToRead bool
 }
 
 // PostIterator : ブックマークデータを逐次取得する
@@ -26,10 +38,13 @@ type PostIterator interface {
 	// Next : ブックマークデータを逐次取得する
 	// データが無くなったら *Post == nil && error == nilを返す
 	Next() (*Post, error)
+	// Close : 読み込み元のファイルやHTTPレスポンスを閉じる
+	Close() error
 }
 
 type postIterator struct {
 	p *xpp.XMLPullParser
+	c io.Closer
 }
 
 // GetAll : ユーザーのブックマークを全て取得する
@@ -39,6 +54,7 @@ type postIterator struct {
 // Example:
 //   ctx := context.Background()
 //   itr, err := pinboard.GetAll(ctx, pinboardAPIToken, webtools.CacheOptions{CacheExpire: 24 * time.Hour, CacheDir: cacheDir})
+//   defer itr.Close()
 //   for {
 //     post, err := itr.Next()
 //     if err != nil {
@@ -57,32 +73,54 @@ func GetAll(ctx context.Context, apiToken string, co webtools.CacheOptions) (Pos
 	if err != nil {
 		return nil, err
 	}
-	if res.Body != nil {
-		defer res.Body.Close()
-	}
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return nil, errors.WithStack(err)
+	if res.Body == nil {
+		return nil, errors.New("bad request")
 	}
-	if bytes.Equal(body, []byte("API requires authentication")) {
-		return nil, errors.New(string(body))
+	// レスポンスは全体を読み込まずに、先頭だけ確認してから逐次パースする
+	br := bufio.NewReader(res.Body)
+	head, _ := br.Peek(len(authError))
+	if bytes.Equal(head, authError) {
+		res.Body.Close()
+		return nil, errors.New(string(authError))
 	}
-	if len(body) == 0 {
+	if len(head) == 0 {
+		res.Body.Close()
 		return nil, errors.New("bad request")
 	}
-	return parseAllData(bytes.NewReader(body))
+	itr, err := parseAllData(br, res.Body)
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	return itr, nil
 }
 
+var authError = []byte("API requires authentication")
+
 // LoadFile : ファイルからブックマークを取得する
+// ファイル全体をメモリに読み込まずに、逐次パースする。gzip(.gz)かzstd(.zst)で圧縮されたファイルはそのまま展開しながら読み込む
+// 読み終わったらCloseでファイルを閉じる
 func LoadFile(filePath string) (PostIterator, error) {
-	body, err := ioutil.ReadFile(filePath)
+	r, err := fileutil.Open(filePath)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, err
+	}
+	itr, err := parseAllData(r, r)
+	if err != nil {
+		r.Close()
+		return nil, err
 	}
-	return parseAllData(bytes.NewReader(body))
+	return itr, nil
 }
 
-func parseAllData(r io.Reader) (PostIterator, error) {
+// Parse : posts/allと同じ形式のXMLからブックマークを取得する
+// rはCloseしないので、呼び出し元で閉じる
+func Parse(r io.Reader) (PostIterator, error) {
+	return parseAllData(r, nil)
+}
+
+// parseAllData : cがnilでない場合は、イテレータのCloseで閉じる
+func parseAllData(r io.Reader, c io.Closer) (PostIterator, error) {
 	p := xpp.NewXMLPullParser(r, true, newReaderLabel)
 
 	err := find(p, xpp.StartTag)
@@ -93,7 +131,7 @@ func parseAllData(r io.Reader) (PostIterator, error) {
 		return nil, errors.New("bad data")
 	}
 
-	return &postIterator{p: p}, nil
+	return &postIterator{p: p, c: c}, nil
 }
 
 func (itr *postIterator) Next() (*Post, error) {
@@ -108,20 +146,42 @@ func (itr *postIterator) Next() (*Post, error) {
 		}
 		href := p.Attribute("href")
 		description := p.Attribute("description")
-		shared := p.Attribute("shared")
 		tag := p.Attribute("tag")
-		if shared == "no" { // 非公開のブックマークを除外
-			continue
-		}
 		if href == "" || description == "" {
 			continue
 		}
-		p := Post{Title: description, Href: href, Tags: strings.Split(tag, " ")}
-		return &p, nil
+		post := &Post{
+			Title:    description,
+			Href:     href,
+			Tags:     strings.Split(tag, " "),
+			Extended: p.Attribute("extended"),
+			Time:     parseTime(p.Attribute("time")),
+			Hash:     p.Attribute("hash"),
+			Meta:     p.Attribute("meta"),
+			Private:  p.Attribute("shared") == "no",
+			ToRead:   p.Attribute("toread") == "yes",
+		}
+		return post, nil
 	}
 	return nil, nil
 }
 
+func (itr *postIterator) Close() error {
+	if itr.c == nil {
+		return nil
+	}
+	return errors.WithStack(itr.c.Close())
+}
+
+// parseTime : APIの日時を変換する。空 or 不正な形式の場合はゼロ値
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
 // github.com/mmcdole/gofeed/internal/shared/NewReaderLabel
 func newReaderLabel(label string, input io.Reader) (io.Reader, error) {
 	conv, err := charset.NewReaderLabel(label, input)
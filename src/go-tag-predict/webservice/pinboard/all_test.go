@@ -13,7 +13,7 @@ func ExampleParseAllData() {
 	<post href="https://3" description="c" tag="にほんご b">
 </posts>
 	`)
-	itr, err := parseAllData(bytes.NewReader(body))
+	itr, err := parseAllData(bytes.NewReader(body), nil)
 	fmt.Println(err)
 	i := 0
 	for {
@@ -0,0 +1,359 @@
+package pinboard
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultBaseURL : Pinboard APIのURL
+const DefaultBaseURL = "https://api.pinboard.in/v1/"
+
+// Pinboardのレート制限 (https://pinboard.in/api/)
+const (
+	// DefaultInterval : APIの呼び出しは3秒に1回まで
+	DefaultInterval = 3 * time.Second
+	// DefaultAllInterval : posts/allは5分に1回まで
+	DefaultAllInterval = 5 * time.Minute
+)
+
+// TimeFormat : APIの日時の形式 (UTC)
+const TimeFormat = "2006-01-02T15:04:05Z"
+
+// ErrItemExists : posts/addで、replace=noの場合に既に同じURLのブックマークがある
+var ErrItemExists = errors.New("item already exists")
+
+// ErrItemNotFound : posts/deleteで、指定したURLのブックマークがない
+var ErrItemNotFound = errors.New("item not found")
+
+// Client : Pinboard APIクライアント
+//
+// レート制限を超えないように、呼び出し間隔を空けて順番にリクエストする
+// 429 Too Many Requestsの場合は、待ち時間を倍にしながらMaxRetries回までやり直す
+// DryRunの場合は、ブックマークを変更するAPI(add/delete)を呼ばずに、呼び出す内容をDryRunOutputに出力する
+// BaseURLを変えると、httptestのサーバーなどに向けられる
+type Client struct {
+	BaseURL      string
+	Token        string
+	Interval     time.Duration
+	AllInterval  time.Duration
+	MaxRetries   int
+	RetryWait    time.Duration
+	DryRun       bool
+	DryRunOutput io.Writer
+	HTTPClient   *http.Client
+
+	mutex   sync.Mutex
+	last    time.Time
+	lastAll time.Time
+}
+
+// NewClient : コンストラクタ
+func NewClient(token string) *Client {
+	return &Client{
+		BaseURL:      DefaultBaseURL,
+		Token:        token,
+		Interval:     DefaultInterval,
+		AllInterval:  DefaultAllInterval,
+		MaxRetries:   3,
+		RetryWait:    10 * time.Second,
+		DryRunOutput: os.Stderr,
+		HTTPClient:   &http.Client{Transport: &http.Transport{DisableKeepAlives: true}},
+	}
+}
+
+// Update : 最後にブックマークが追加/更新/削除された日時を取得する (posts/update)
+func (c *Client) Update(ctx context.Context) (time.Time, error) {
+	body, err := c.get(ctx, "posts/update", url.Values{})
+	if err != nil {
+		return time.Time{}, err
+	}
+	v := struct {
+		Time string `xml:"time,attr"`
+	}{}
+	if err := xml.Unmarshal(body, &v); err != nil {
+		return time.Time{}, errors.WithStack(err)
+	}
+	t, err := time.Parse(TimeFormat, v.Time)
+	if err != nil {
+		return time.Time{}, errors.WithStack(err)
+	}
+	return t, nil
+}
+
+// WriteAllXML : ブックマークをXMLで取得して、wに書き込む (posts/all)
+// fromdtがゼロ値でない場合は、fromdt以降に作成されたブックマークだけを取得する
+// 全件だと膨大になるので、レスポンスはメモリに読み込まずにそのままwへ書き込む
+func (c *Client) WriteAllXML(ctx context.Context, fromdt time.Time, w io.Writer) error {
+	params := url.Values{}
+	if !fromdt.IsZero() {
+		params.Set("fromdt", fromdt.UTC().Format(TimeFormat))
+	}
+	return c.request(ctx, "posts/all", params, func(r io.Reader) error {
+		_, err := io.Copy(w, r)
+		return errors.WithStack(stripQuery(err))
+	})
+}
+
+// AddParams : posts/addのパラメーター
+type AddParams struct {
+	URL         string
+	Description string
+	Extended    string
+	Tags        []string
+	// Replace : falseの場合、既に同じURLのブックマークがあればErrItemExistsを返す
+	Replace bool
+	Shared  bool
+	ToRead  bool
+	// Time : ブックマークの作成日時 (ゼロ値の場合は現在日時)
+	Time time.Time
+}
+
+// Add : ブックマークを追加する (posts/add)
+func (c *Client) Add(ctx context.Context, p *AddParams) error {
+	params := url.Values{}
+	params.Set("url", p.URL)
+	params.Set("description", p.Description)
+	if p.Extended != "" {
+		params.Set("extended", p.Extended)
+	}
+	if len(p.Tags) > 0 {
+		params.Set("tags", strings.Join(p.Tags, " "))
+	}
+	params.Set("replace", yesNo(p.Replace))
+	params.Set("shared", yesNo(p.Shared))
+	params.Set("toread", yesNo(p.ToRead))
+	if !p.Time.IsZero() {
+		params.Set("dt", p.Time.UTC().Format(TimeFormat))
+	}
+	return c.result(ctx, "posts/add", params)
+}
+
+// AddTags : 既存のブックマークにタグを追加する
+// posts/addは全ての項目を置き換えるので、posts/getで取得した説明、作成日時、公開/あとで読むの設定を引き継いで保存し直す
+func (c *Client) AddTags(ctx context.Context, rawurl string, tags []string) error {
+	p, err := c.getXMLPost(ctx, rawurl)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return errors.WithStack(ErrItemNotFound)
+	}
+	params := &AddParams{
+		URL:         p.Href,
+		Description: p.Description,
+		Extended:    p.Extended,
+		Tags:        strings.Fields(p.Tag),
+		Replace:     true,
+		Shared:      p.Shared != "no",
+		ToRead:      p.ToRead == "yes",
+	}
+	if p.Time != "" {
+		if params.Time, err = time.Parse(TimeFormat, p.Time); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	for _, tag := range tags {
+		if !containsTag(params.Tags, tag) {
+			params.Tags = append(params.Tags, tag)
+		}
+	}
+	return c.Add(ctx, params)
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Get : URLのブックマークを取得する (posts/get)
+// ブックマークがない場合はnilを返す
+func (c *Client) Get(ctx context.Context, rawurl string) (*Post, error) {
+	p, err := c.getXMLPost(ctx, rawurl)
+	if p == nil || err != nil {
+		return nil, err
+	}
+	return p.post(), nil
+}
+
+func (c *Client) getXMLPost(ctx context.Context, rawurl string) (*xmlPost, error) {
+	params := url.Values{}
+	params.Set("url", rawurl)
+	body, err := c.get(ctx, "posts/get", params)
+	if err != nil {
+		return nil, err
+	}
+	posts := &xmlPosts{}
+	if err := xml.Unmarshal(body, posts); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(posts.Posts) == 0 {
+		return nil, nil
+	}
+	return posts.Posts[0], nil
+}
+
+// Delete : URLのブックマークを削除する (posts/delete)
+func (c *Client) Delete(ctx context.Context, rawurl string) error {
+	params := url.Values{}
+	params.Set("url", rawurl)
+	return c.result(ctx, "posts/delete", params)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// result : ブックマークを変更するAPIを呼び出して、<result code="done" />を確認する
+func (c *Client) result(ctx context.Context, method string, params url.Values) error {
+	if c.DryRun {
+		fmt.Fprintln(c.DryRunOutput, "DRY-RUN: GET "+method+"?"+params.Encode())
+		return nil
+	}
+	body, err := c.get(ctx, method, params)
+	if err != nil {
+		return err
+	}
+	v := struct {
+		Code string `xml:"code,attr"`
+	}{}
+	if err := xml.Unmarshal(body, &v); err != nil {
+		return errors.WithStack(err)
+	}
+	switch v.Code {
+	case "done":
+		return nil
+	case ErrItemExists.Error():
+		return errors.WithStack(ErrItemExists)
+	case ErrItemNotFound.Error():
+		return errors.WithStack(ErrItemNotFound)
+	}
+	return errors.New("pinboard " + method + ": " + v.Code)
+}
+
+func (c *Client) get(ctx context.Context, method string, params url.Values) ([]byte, error) {
+	var body []byte
+	err := c.request(ctx, method, params, func(r io.Reader) error {
+		var err error
+		body, err = ioutil.ReadAll(r)
+		return errors.WithStack(stripQuery(err))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// request : APIを呼び出して、成功した場合のレスポンスをreadで読み込む
+// エラーや429の場合はreadを呼ばないので、やり直してもreadには1回分のレスポンスだけが渡る
+func (c *Client) request(ctx context.Context, method string, params url.Values, read func(r io.Reader) error) error {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+
+	params.Set("auth_token", c.Token)
+	rawurl := strings.TrimSuffix(c.BaseURL, "/") + "/" + method + "?" + params.Encode()
+	wait := c.RetryWait
+	for retry := 0; ; retry++ {
+		if err := sleepUntil(ctx, c.last.Add(c.Interval)); err != nil {
+			return err
+		}
+		if method == "posts/all" {
+			if err := sleepUntil(ctx, c.lastAll.Add(c.AllInterval)); err != nil {
+				return err
+			}
+		}
+		res, err := c.do(ctx, rawurl)
+		c.last = time.Now()
+		if method == "posts/all" {
+			c.lastAll = c.last
+		}
+		if err != nil {
+			return errors.Wrap(err, "pinboard "+method)
+		}
+		if res.StatusCode == http.StatusTooManyRequests && retry < c.MaxRetries {
+			res.Body.Close()
+			d := wait
+			if s, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+				d = time.Duration(s) * time.Second
+			}
+			if err := sleepUntil(ctx, c.last.Add(d)); err != nil {
+				return err
+			}
+			wait *= 2
+			continue
+		}
+		if res.StatusCode >= 400 {
+			res.Body.Close()
+			return errors.New("pinboard " + method + ": " + res.Status)
+		}
+		err = read(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return errors.Wrap(err, "pinboard "+method)
+		}
+		return nil
+	}
+}
+
+func (c *Client) do(ctx context.Context, rawurl string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", rawurl, nil)
+	if err != nil {
+		return nil, errors.WithStack(stripQuery(err))
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.WithStack(stripQuery(err))
+	}
+	return res, nil
+}
+
+// stripQuery : auth_tokenがエラーメッセージやログに残らないように、url.ErrorのURLからクエリを取り除く
+func stripQuery(err error) error {
+	e, ok := err.(*url.Error)
+	if !ok {
+		return err
+	}
+	rawurl := e.URL
+	if i := strings.Index(rawurl, "?"); i >= 0 {
+		rawurl = rawurl[:i]
+	}
+	return &url.Error{Op: e.Op, URL: rawurl, Err: e.Err}
+}
+
+// sleepUntil : tまで待機する
+func sleepUntil(ctx context.Context, t time.Time) error {
+	d := time.Until(t)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,275 @@
+package pinboard
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Pinboard APIの代わりのサーバー
+// postsは作成日時 => <post>で、posts/allは新しい順に返す
+func newTestServer(update *string, posts map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("auth_token") != "user:TOKEN" {
+			http.Error(w, "API requires authentication", http.StatusUnauthorized)
+			return
+		}
+		fmt.Println(strings.TrimSpace("request: " + r.URL.Path + " " + r.URL.Query().Get("fromdt")))
+		switch r.URL.Path {
+		case "/v1/posts/update":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8" ?><update time="%s" />`, *update)
+		case "/v1/posts/all":
+			fromdt := r.URL.Query().Get("fromdt")
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8" ?>`+"\n"+`<posts user="user">`+"\n")
+			times := make([]string, 0, len(posts))
+			for t := range posts {
+				if t >= fromdt {
+					times = append(times, t)
+				}
+			}
+			sort.Sort(sort.Reverse(sort.StringSlice(times)))
+			for _, t := range times {
+				fmt.Fprint(w, posts[t]+"\n")
+			}
+			fmt.Fprint(w, "</posts>\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func ExampleSync() {
+	update := "2017-04-01T00:00:00Z"
+	posts := map[string]string{
+		"2017-03-01T00:00:00Z": `<post href="http://1" time="2017-03-01T00:00:00Z" description="a" extended="" tag="go" hash="1" shared="yes" toread="no" />`,
+	}
+	ts := newTestServer(&update, posts)
+	defer ts.Close()
+
+	dir, _ := ioutil.TempDir("", "pinboard")
+	defer os.RemoveAll(dir)
+	mirrorPath := filepath.Join(dir, "mirror.xml")
+
+	c := NewClient("user:TOKEN")
+	c.BaseURL = ts.URL + "/v1/"
+	c.Interval = 0
+	c.AllInterval = 0
+	ctx := context.Background()
+
+	// 初回は全件
+	fmt.Println(Sync(ctx, c, mirrorPath))
+	// 変更がなければposts/allを呼ばない
+	fmt.Println(Sync(ctx, c, mirrorPath))
+	// 前回の同期以降に作成されたブックマークだけを取得する
+	update = "2017-04-02T00:00:00Z"
+	posts["2017-04-02T00:00:00Z"] = `<post href="http://2" time="2017-04-02T00:00:00Z" description="b &amp; c" extended="" tag="web にほんご" hash="2" shared="no" toread="yes" />`
+	fmt.Println(Sync(ctx, c, mirrorPath))
+	// 同じURLのブックマークは、取得したもので上書きする
+	update = "2017-04-03T00:00:00Z"
+	delete(posts, "2017-03-01T00:00:00Z")
+	posts["2017-04-03T00:00:00Z"] = `<post href="http://1" time="2017-04-03T00:00:00Z" description="a2" extended="" tag="go" hash="1" shared="yes" toread="no" />`
+	fmt.Println(Sync(ctx, c, mirrorPath))
+	// 既存のブックマークの編集は、作成日時で絞り込んでも取得できないので、全件を取得し直す
+	update = "2017-04-04T00:00:00Z"
+	posts["2017-04-02T00:00:00Z"] = `<post href="http://2" time="2017-04-02T00:00:00Z" description="b &amp; c" extended="" tag="web edited" hash="2" shared="no" toread="yes" />`
+	fmt.Println(Sync(ctx, c, mirrorPath))
+	// 削除も同じ
+	update = "2017-04-05T00:00:00Z"
+	delete(posts, "2017-04-03T00:00:00Z")
+	fmt.Println(Sync(ctx, c, mirrorPath))
+
+	body, _ := ioutil.ReadFile(mirrorPath)
+	fmt.Print(string(body))
+
+	itr, _ := LoadFile(mirrorPath)
+	defer itr.Close()
+	for {
+		post, _ := itr.Next()
+		if post == nil {
+			break
+		}
+		fmt.Println(post.Href, post.Title, post.Tags, post.Private)
+	}
+
+	c.Token = "user:BAD"
+	_, err := Sync(ctx, c, mirrorPath)
+	fmt.Println(strings.Contains(err.Error(), "401"))
+
+	// Output:
+	// request: /v1/posts/update
+	// request: /v1/posts/all
+	// 1 <nil>
+	// request: /v1/posts/update
+	// 0 <nil>
+	// request: /v1/posts/update
+	// request: /v1/posts/all 2017-04-01T00:00:00Z
+	// 1 <nil>
+	// request: /v1/posts/update
+	// request: /v1/posts/all 2017-04-02T00:00:00Z
+	// 2 <nil>
+	// request: /v1/posts/update
+	// request: /v1/posts/all 2017-04-03T00:00:00Z
+	// request: /v1/posts/all
+	// 2 <nil>
+	// request: /v1/posts/update
+	// request: /v1/posts/all 2017-04-04T00:00:00Z
+	// request: /v1/posts/all
+	// 1 <nil>
+	// <?xml version="1.0" encoding="UTF-8"?>
+	// <posts user="user" dt="2017-04-05T00:00:00Z">
+	// 	<post href="http://2" time="2017-04-02T00:00:00Z" description="b &amp; c" extended="" tag="web edited" hash="2" shared="no" toread="yes"></post>
+	// </posts>
+	// http://2 b & c [web edited] true
+	// true
+}
+
+func ExampleClient() {
+	bookmarks := map[string]string{}
+	tooManyRequests := 1
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if tooManyRequests > 0 {
+			tooManyRequests--
+			fmt.Println("429:", r.URL.Path)
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		fmt.Println(strings.TrimSpace(strings.Join([]string{"request:", r.URL.Path, q.Get("url"), q.Get("tags"), q.Get("shared"), q.Get("toread")}, " ")))
+		switch r.URL.Path {
+		case "/v1/posts/add":
+			if _, ok := bookmarks[q.Get("url")]; ok && q.Get("replace") == "no" {
+				fmt.Fprint(w, `<result code="item already exists" />`)
+				return
+			}
+			bookmarks[q.Get("url")] = fmt.Sprintf(`<post href="%s" description="%s" tag="%s" />`, q.Get("url"), q.Get("description"), q.Get("tags"))
+			fmt.Fprint(w, `<result code="done" />`)
+		case "/v1/posts/get":
+			fmt.Fprintf(w, `<posts user="user">%s</posts>`, bookmarks[q.Get("url")])
+		case "/v1/posts/delete":
+			if _, ok := bookmarks[q.Get("url")]; !ok {
+				fmt.Fprint(w, `<result code="item not found" />`)
+				return
+			}
+			delete(bookmarks, q.Get("url"))
+			fmt.Fprint(w, `<result code="done" />`)
+		}
+	}))
+	defer ts.Close()
+
+	c := NewClient("user:TOKEN")
+	c.BaseURL = ts.URL + "/v1/"
+	c.Interval = 0
+	c.RetryWait = 0
+	ctx := context.Background()
+
+	add := &AddParams{URL: "http://1", Description: "a", Tags: []string{"golang", "auto:predicted"}, ToRead: true}
+	fmt.Println(c.Add(ctx, add))
+	err := c.Add(ctx, add)
+	fmt.Println(errors.Cause(err) == ErrItemExists)
+	post, err := c.Get(ctx, "http://1")
+	fmt.Println(post.Title, post.Tags, err)
+	fmt.Println(c.Delete(ctx, "http://1"))
+	post, err = c.Get(ctx, "http://1")
+	fmt.Println(post, err)
+	err = c.Delete(ctx, "http://1")
+	fmt.Println(errors.Cause(err) == ErrItemNotFound)
+
+	c.DryRun = true
+	c.DryRunOutput = os.Stdout
+	fmt.Println(c.Add(ctx, add))
+
+	// 接続できない場合も、エラーにauth_tokenを含めない
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	c.BaseURL = closed.URL + "/v1/"
+	c.DryRun = false
+	err = c.Add(ctx, add)
+	fmt.Println(err != nil, strings.Contains(err.Error(), "auth_token"), strings.Contains(err.Error(), "TOKEN"))
+
+	// Output:
+	// 429: /v1/posts/add
+	// request: /v1/posts/add http://1 golang auto:predicted no yes
+	// <nil>
+	// request: /v1/posts/add http://1 golang auto:predicted no yes
+	// true
+	// request: /v1/posts/get http://1
+	// a [golang auto:predicted] <nil>
+	// request: /v1/posts/delete http://1
+	// <nil>
+	// request: /v1/posts/get http://1
+	// <nil> <nil>
+	// request: /v1/posts/delete http://1
+	// true
+	// DRY-RUN: GET posts/add?description=a&replace=no&shared=no&tags=golang+auto%3Apredicted&toread=yes&url=http%3A%2F%2F1
+	// <nil>
+	// true false false
+}
+
+func ExampleClient_AddTags() {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch r.URL.Path {
+		case "/v1/posts/get":
+			if q.Get("url") == "http://1" {
+				fmt.Fprint(w, `<posts user="user"><post href="http://1" time="2017-03-01T00:00:00Z" description="a" extended="memo" tag="go" shared="no" toread="yes" /></posts>`)
+				return
+			}
+			fmt.Fprint(w, `<posts user="user"></posts>`)
+		case "/v1/posts/add":
+			fmt.Println("request:", r.URL.Path, q.Get("url"), q.Get("description"), q.Get("extended"), q.Get("tags"), q.Get("dt"), q.Get("replace"), q.Get("shared"), q.Get("toread"))
+			fmt.Fprint(w, `<result code="done" />`)
+		}
+	}))
+	defer ts.Close()
+
+	c := NewClient("user:TOKEN")
+	c.BaseURL = ts.URL + "/v1/"
+	c.Interval = 0
+	ctx := context.Background()
+
+	fmt.Println(c.AddTags(ctx, "http://1", []string{"golang", "go"}))
+	err := c.AddTags(ctx, "http://2", []string{"golang"})
+	fmt.Println(errors.Cause(err) == ErrItemNotFound)
+
+	// Output:
+	// request: /v1/posts/add http://1 a memo go golang 2017-03-01T00:00:00Z yes no yes
+	// <nil>
+	// true
+}
+
+func ExampleWriteFile() {
+	dir, _ := ioutil.TempDir("", "pinboard")
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "posts.xml")
+
+	posts := []*Post{
+		{Title: "a", Href: "http://1", Tags: []string{"go", "web"}, Extended: "memo", Time: time.Date(2017, 3, 1, 9, 0, 0, 0, time.FixedZone("JST", 9*60*60)), Private: true, ToRead: true},
+		{Title: "b & c", Href: "http://2", Tags: []string{}},
+	}
+	fmt.Println(WriteFile(filePath, posts))
+
+	itr, _ := LoadFile(filePath)
+	defer itr.Close()
+	for {
+		post, _ := itr.Next()
+		if post == nil {
+			break
+		}
+		fmt.Println(post.Href, post.Title, len(post.Tags), post.Tags, post.Extended, post.Time.Format(TimeFormat), post.Private, post.ToRead)
+	}
+
+	// Output:
+	// <nil>
+	// http://1 a 2 [go web] memo 2017-03-01T00:00:00Z true true
+	// http://2 b & c 1 []  0001-01-01T00:00:00Z false false
+}
@@ -0,0 +1,359 @@
+package pinboard
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// xmlPosts : posts/allのXML
+// dtはミラーファイルにだけ書き込む、最後に同期したposts/updateの日時
+type xmlPosts struct {
+	XMLName xml.Name   `xml:"posts"`
+	User    string     `xml:"user,attr,omitempty"`
+	DT      string     `xml:"dt,attr,omitempty"`
+	Posts   []*xmlPost `xml:"post"`
+}
+
+// xmlPost : posts/allのXMLの<post>要素
+type xmlPost struct {
+	Href        string `xml:"href,attr"`
+	Time        string `xml:"time,attr,omitempty"`
+	Description string `xml:"description,attr"`
+	Extended    string `xml:"extended,attr"`
+	Tag         string `xml:"tag,attr"`
+	Hash        string `xml:"hash,attr,omitempty"`
+	Meta        string `xml:"meta,attr,omitempty"`
+	Shared      string `xml:"shared,attr,omitempty"`
+	ToRead      string `xml:"toread,attr,omitempty"`
+}
+
+func (p *xmlPost) post() *Post {
+	return &Post{
+		Title:    p.Description,
+		Href:     p.Href,
+		Tags:     strings.Fields(p.Tag),
+		Extended: p.Extended,
+		Time:     parseTime(p.Time),
+		Hash:     p.Hash,
+		Meta:     p.Meta,
+		Private:  p.Shared == "no",
+		ToRead:   p.ToRead == "yes",
+	}
+}
+
+func newXMLPost(p *Post) *xmlPost {
+	x := &xmlPost{
+		Href:        p.Href,
+		Description: p.Title,
+		Extended:    p.Extended,
+		Tag:         strings.Join(p.Tags, " "),
+		Hash:        p.Hash,
+		Meta:        p.Meta,
+		Shared:      yesNo(!p.Private),
+		ToRead:      yesNo(p.ToRead),
+	}
+	if !p.Time.IsZero() {
+		x.Time = p.Time.UTC().Format(TimeFormat)
+	}
+	return x
+}
+
+// Sync : APIから取得したブックマークをミラーファイルに反映する
+//
+// ミラーファイルがない場合は全件を取得する
+// ある場合は、posts/updateで変更がなければ何もせず、変更があれば前回の同期以降に作成されたブックマークだけを取得して追加/上書きする
+//
+// posts/allのfromdtは作成日時で絞り込むので、既存のブックマークの編集や削除は取得できない
+// posts/updateの日時が、取得したブックマークの最新の作成日時より新しい場合(新しいブックマークがない場合を含む)は、
+// 最後の変更が編集か削除なので、全件を取得し直してミラーファイルを置き換える
+//
+// 全件は膨大になるので、posts/allのレスポンスは一時ファイルに書き込み、ミラーファイルと1件ずつ突き合わせて書き出す
+// メモリに保持するのは、取得したブックマークのURLだけ
+// 追加/上書きしたブックマークの数を返す (全件を取得した場合は全件の数)
+func Sync(ctx context.Context, c *Client, mirrorPath string) (int, error) {
+	mirror, err := readMirrorHeader(mirrorPath)
+	if err != nil {
+		return 0, err
+	}
+	update, err := c.Update(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var fromdt time.Time
+	if mirror != nil {
+		if fromdt, err = time.Parse(TimeFormat, mirror.DT); err != nil {
+			return 0, errors.Wrap(err, "bad mirror file: "+mirrorPath)
+		}
+		if !update.After(fromdt) {
+			return 0, nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(mirrorPath), 0700); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	f, err := ioutil.TempFile(filepath.Dir(mirrorPath), filepath.Base(mirrorPath)+".fetch")
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := fetchAllXML(ctx, c, fromdt, f); err != nil {
+		return 0, err
+	}
+	if mirror != nil {
+		count, newest, err := scanPosts(f)
+		if err != nil {
+			return 0, errors.Wrap(err, "bad posts/all response")
+		}
+		if count == 0 || update.After(newest) {
+			mirror = nil
+			if err := fetchAllXML(ctx, c, time.Time{}, f); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return mergeMirror(mirrorPath, mirror, bufio.NewReader(f), update)
+}
+
+// fetchAllXML : posts/allのレスポンスをfに書き込んで、先頭に戻す
+func fetchAllXML(ctx context.Context, c *Client, fromdt time.Time, f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return errors.WithStack(err)
+	}
+	w := bufio.NewWriter(f)
+	if err := c.WriteAllXML(ctx, fromdt, w); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	_, err := f.Seek(0, io.SeekStart)
+	return errors.WithStack(err)
+}
+
+// scanPosts : posts/allのレスポンスのブックマークの数と、最新の作成日時を取得して、先頭に戻す
+func scanPosts(f *os.File) (int, time.Time, error) {
+	dec := xml.NewDecoder(bufio.NewReader(f))
+	if _, err := readPostsStart(dec); err != nil {
+		return 0, time.Time{}, err
+	}
+	count := 0
+	var newest time.Time
+	err := decodePosts(dec, func(p *xmlPost) error {
+		count++
+		if t := parseTime(p.Time); t.After(newest) {
+			newest = t
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	return count, newest, errors.WithStack(err)
+}
+
+// mergeMirror : 取得したブックマーク(fetched)と既存のミラーファイルを合わせて、ミラーファイルを書き直す
+//
+// 取得したブックマークを先に、posts/allの順(新しい順)のまま書き出し、
+// 続けて既存のミラーファイルから、取得したブックマークと同じURLのものを除いて書き出す
+// 書き出したうち、取得したブックマークの数を返す
+func mergeMirror(mirrorPath string, mirror *xmlPosts, fetched io.Reader, update time.Time) (int, error) {
+	dec := xml.NewDecoder(fetched)
+	root, err := readPostsStart(dec)
+	if err != nil {
+		return 0, errors.Wrap(err, "bad posts/all response")
+	}
+	header := &xmlPosts{User: root.User, DT: update.UTC().Format(TimeFormat)}
+	if mirror != nil && header.User == "" {
+		header.User = mirror.User
+	}
+
+	count := 0
+	err = writeMirror(mirrorPath, header, func(write func(p *xmlPost) error) error {
+		var hrefs map[string]struct{}
+		if mirror != nil {
+			hrefs = make(map[string]struct{}, 1024)
+		}
+		if err := decodePosts(dec, func(p *xmlPost) error {
+			if hrefs != nil {
+				hrefs[p.Href] = struct{}{}
+			}
+			count++
+			return write(p)
+		}); err != nil {
+			return errors.Wrap(err, "bad posts/all response")
+		}
+		if mirror == nil {
+			return nil
+		}
+
+		f, err := os.Open(mirrorPath)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer f.Close()
+		dec := xml.NewDecoder(bufio.NewReader(f))
+		if _, err := readPostsStart(dec); err != nil {
+			return errors.Wrap(err, "bad mirror file: "+mirrorPath)
+		}
+		err = decodePosts(dec, func(p *xmlPost) error {
+			if _, ok := hrefs[p.Href]; ok {
+				return nil
+			}
+			return write(p)
+		})
+		return errors.Wrap(err, "bad mirror file: "+mirrorPath)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// readMirrorHeader : ミラーファイルの<posts>の属性(user, dt)だけを読み込む
+// ファイルがない場合はnilを返す
+func readMirrorHeader(mirrorPath string) (*xmlPosts, error) {
+	f, err := os.Open(mirrorPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	mirror, err := readPostsStart(xml.NewDecoder(bufio.NewReader(f)))
+	if err != nil {
+		return nil, errors.Wrap(err, "bad mirror file: "+mirrorPath)
+	}
+	return mirror, nil
+}
+
+// readPostsStart : <posts>の開始タグまで読み進めて、その属性を返す
+func readPostsStart(dec *xml.Decoder) (*xmlPosts, error) {
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		se, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if se.Name.Local != "posts" {
+			return nil, errors.New("bad data")
+		}
+		res := &xmlPosts{}
+		for _, attr := range se.Attr {
+			switch attr.Name.Local {
+			case "user":
+				res.User = attr.Value
+			case "dt":
+				res.DT = attr.Value
+			}
+		}
+		return res, nil
+	}
+}
+
+// decodePosts : <posts>の子要素の<post>を1件ずつ読み込んで、fnを呼ぶ
+func decodePosts(dec *xml.Decoder, fn func(p *xmlPost) error) error {
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "post" {
+				if err := dec.Skip(); err != nil {
+					return errors.WithStack(err)
+				}
+				continue
+			}
+			p := &xmlPost{}
+			if err := dec.DecodeElement(p, &t); err != nil {
+				return errors.WithStack(err)
+			}
+			if err := fn(p); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			// </posts>
+			return nil
+		}
+	}
+}
+
+// WriteFile : ブックマークをLoadFileで読み込めるXMLファイルに書き出す
+func WriteFile(filePath string, posts []*Post) error {
+	return writeMirror(filePath, &xmlPosts{}, func(write func(p *xmlPost) error) error {
+		for _, p := range posts {
+			if err := write(newXMLPost(p)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeMirror : <posts>の属性をheaderにして、fnでwriteに渡したブックマークを書き出す
+// 書き込み途中で中断しても壊れないように、一時ファイルに書いてから置き換える
+func writeMirror(mirrorPath string, header *xmlPosts, fn func(write func(p *xmlPost) error) error) error {
+	if err := os.MkdirAll(filepath.Dir(mirrorPath), 0700); err != nil {
+		return errors.WithStack(err)
+	}
+	f, err := ioutil.TempFile(filepath.Dir(mirrorPath), filepath.Base(mirrorPath)+".tmp")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	w.WriteString(xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	start := xml.StartElement{Name: xml.Name{Local: "posts"}}
+	if header.User != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "user"}, Value: header.User})
+	}
+	if header.DT != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "dt"}, Value: header.DT})
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return errors.WithStack(err)
+	}
+	postStart := xml.StartElement{Name: xml.Name{Local: "post"}}
+	if err := fn(func(p *xmlPost) error {
+		return errors.WithStack(enc.EncodeElement(p, postStart))
+	}); err != nil {
+		return err
+	}
+	if err := enc.EncodeToken(start.End()); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := enc.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	w.WriteString("\n")
+	if err := w.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(f.Name(), mirrorPath))
+}